@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// AgeStats summarises how long open MRs have been around.
+type AgeStats struct {
+	Count         int
+	Median        time.Duration
+	OlderThanWeek int
+}
+
+// mrAgeStats measures age from created_at, falling back to updated_at for
+// instances that don't send it.
+func mrAgeStats(mrs []MR, now time.Time) AgeStats {
+	ages := make([]time.Duration, 0, len(mrs))
+	s := AgeStats{Count: len(mrs)}
+	for _, m := range mrs {
+		created := m.CreatedAt.Time
+		if created.IsZero() {
+			created = m.UpdatedAt.Time
+		}
+		age := now.Sub(created)
+		ages = append(ages, age)
+		if age > 7*24*time.Hour {
+			s.OlderThanWeek++
+		}
+	}
+	if len(ages) == 0 {
+		return s
+	}
+	sort.Slice(ages, func(i, j int) bool { return ages[i] < ages[j] })
+	mid := len(ages) / 2
+	if len(ages)%2 == 1 {
+		s.Median = ages[mid]
+	} else {
+		s.Median = (ages[mid-1] + ages[mid]) / 2
+	}
+	return s
+}
+
+// MedianText renders the median age as "3d", "5h" or "12m".
+func (s AgeStats) MedianText() string {
+	switch d := s.Median; {
+	case d >= 24*time.Hour:
+		return fmt.Sprintf("%dd", int(d.Hours()/24))
+	case d >= time.Hour:
+		return fmt.Sprintf("%dh", int(d.Hours()))
+	default:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	}
+}
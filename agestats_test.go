@@ -0,0 +1,43 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMRAgeStats(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	ago := func(d time.Duration) MR { return MR{CreatedAt: Timestamp{now.Add(-d)}} }
+	day := 24 * time.Hour
+	for _, tt := range []struct {
+		name string
+		mrs  []MR
+		want AgeStats
+	}{
+		{"empty", nil, AgeStats{}},
+		{"one MR", []MR{ago(3 * day)}, AgeStats{Count: 1, Median: 3 * day}},
+		{"odd count", []MR{ago(9 * day), ago(time.Hour), ago(2 * day)}, AgeStats{Count: 3, Median: 2 * day, OlderThanWeek: 1}},
+		{"even count", []MR{ago(8 * day), ago(2 * day), ago(4 * day), ago(10 * day)}, AgeStats{Count: 4, Median: 6 * day, OlderThanWeek: 2}},
+		{"exactly a week is not older", []MR{ago(7 * day)}, AgeStats{Count: 1, Median: 7 * day}},
+		{"falls back to updated_at", []MR{{UpdatedAt: Timestamp{now.Add(-5 * time.Hour)}}}, AgeStats{Count: 1, Median: 5 * time.Hour}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mrAgeStats(tt.mrs, now); got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMedianText(t *testing.T) {
+	for d, want := range map[time.Duration]string{
+		50 * time.Hour:   "2d",
+		5 * time.Hour:    "5h",
+		12 * time.Minute: "12m",
+		0:                "0m",
+	} {
+		if got := (AgeStats{Median: d}).MedianText(); got != want {
+			t.Errorf("MedianText(%v) = %q, want %q", d, got, want)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// GET /api/v1/dashboard: the data behind the page as JSON, after snoozes
+// and the ?q=, ?team=, ?milestone= and ?todo_action= filters, for widgets
+// that would otherwise scrape the HTML. Field names are part of the API;
+// add fields, don't rename them.
+
+type apiDashboard struct {
+	User        string       `json:"user"`
+	GeneratedAt time.Time    `json:"generated_at"`
+	PolledAt    *time.Time   `json:"polled_at,omitempty"`
+	MRs         []apiMR      `json:"mrs"`
+	TeamMRs     []apiMR      `json:"team_mrs"`
+	Approved    []apiMR      `json:"approved"`
+	Issues      []apiIssue   `json:"issues"`
+	Todos       []apiTodo    `json:"todos"`
+	Snoozed     int          `json:"snoozed"`
+	Failures    []apiFailure `json:"failures"`
+}
+
+type apiMR struct {
+	Key         string    `json:"key"` // as used by /mrs/snooze
+	Host        string    `json:"host"`
+	Provider    string    `json:"provider"`
+	Reference   string    `json:"reference"`
+	Title       string    `json:"title"`
+	WebURL      string    `json:"web_url"`
+	Author      string    `json:"author"`
+	Reviewers   []string  `json:"reviewers"`
+	Labels      []string  `json:"labels"`
+	Milestone   string    `json:"milestone,omitempty"`
+	Pipeline    string    `json:"pipeline,omitempty"`    // status; empty without one
+	MergeState  string    `json:"merge_state,omitempty"` // mergeable, checking or blocked
+	Conflicts   bool      `json:"conflicts"`
+	ReviewState string    `json:"review_state,omitempty"`
+	FromTeam    bool      `json:"from_team"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+type apiIssue struct {
+	Host      string    `json:"host"`
+	Reference string    `json:"reference"`
+	Title     string    `json:"title"`
+	WebURL    string    `json:"web_url"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type apiTodo struct {
+	ID        int       `json:"id"`
+	Host      string    `json:"host"`
+	Action    string    `json:"action"`
+	Class     string    `json:"class"` // action or info
+	Type      string    `json:"target_type"`
+	Title     string    `json:"title"`
+	WebURL    string    `json:"web_url"`
+	Project   string    `json:"project"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type apiFailure struct {
+	Part    string `json:"part"` // e.g. part.reviewer
+	Host    string `json:"host,omitempty"`
+	Message string `json:"message"`
+}
+
+func apiMRs(mrs []MR) []apiMR {
+	out := make([]apiMR, 0, len(mrs))
+	for _, m := range mrs {
+		a := apiMR{
+			Key:         m.Key(),
+			Host:        m.Host,
+			Provider:    "gitlab",
+			Reference:   m.References.Full,
+			Title:       m.Title,
+			WebURL:      m.WebURL,
+			Author:      m.Author.Username,
+			Reviewers:   []string{},
+			Labels:      m.Labels,
+			MergeState:  m.MergeState(),
+			Conflicts:   m.HasConflicts,
+			ReviewState: m.ReviewState,
+			FromTeam:    m.FromTeam,
+			CreatedAt:   m.CreatedAt.Time,
+			UpdatedAt:   m.UpdatedAt.Time,
+		}
+		if m.Provider != "" {
+			a.Provider = m.Provider
+		}
+		if a.Labels == nil {
+			a.Labels = []string{}
+		}
+		for _, r := range m.Reviewers {
+			a.Reviewers = append(a.Reviewers, r.Username)
+		}
+		if m.Milestone != nil {
+			a.Milestone = m.Milestone.Title
+		}
+		if m.HeadPipeline != nil {
+			a.Pipeline = m.HeadPipeline.Status
+		}
+		out = append(out, a)
+	}
+	return out
+}
+
+func apiView(d dashboard) apiDashboard {
+	a := apiDashboard{
+		User:        d.User,
+		GeneratedAt: time.Now().UTC(),
+		MRs:         apiMRs(d.MRs),
+		TeamMRs:     apiMRs(d.TeamMRs),
+		Approved:    apiMRs(d.Approved),
+		Issues:      make([]apiIssue, 0, len(d.Issues)),
+		Todos:       make([]apiTodo, 0, len(d.Todos)),
+		Snoozed:     d.Snoozed,
+		Failures:    []apiFailure{},
+	}
+	if !d.PolledAt.IsZero() {
+		a.PolledAt = &d.PolledAt
+	}
+	for _, i := range d.Issues {
+		a.Issues = append(a.Issues, apiIssue{i.Host, i.References.Full, i.Title, i.WebURL, i.UpdatedAt.Time})
+	}
+	for _, t := range d.Todos {
+		a.Todos = append(a.Todos, apiTodo{
+			ID:        t.ID,
+			Host:      t.Host,
+			Action:    t.ActionName,
+			Class:     classifyTodo(t),
+			Type:      t.TargetType,
+			Title:     t.Target.Title,
+			WebURL:    t.Target.WebURL,
+			Project:   t.Project.Name,
+			CreatedAt: t.CreatedAt.Time,
+		})
+	}
+	for _, f := range d.Failures {
+		a.Failures = append(a.Failures, apiFailure(f))
+	}
+	return a
+}
+
+func apiDashboardHandler(w http.ResponseWriter, r *http.Request) {
+	cfg, err := requestConfig(r)
+	if err != nil {
+		writeConfigError(w, r, err)
+		return
+	}
+	queryConfig(r, &cfg)
+	d, err := collectOrPolled(r.Context(), cfg, cfg.Sections)
+	if err != nil {
+		slog.Warn("dashboard incomplete", "err", errorHint(err))
+	}
+	d = prepareDashboard(r, cfg, d, err)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	_ = json.NewEncoder(w).Encode(apiView(d))
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// dashboardCredentialsSet says whether DASHBOARD_USER and DASHBOARD_PASS or
+// DASHBOARD_TOKEN are set, as opposed to the OAuth login alone.
+func dashboardCredentialsSet() bool {
+	return os.Getenv("DASHBOARD_USER") != "" && os.Getenv("DASHBOARD_PASS") != "" || os.Getenv("DASHBOARD_TOKEN") != ""
+}
+
+// dashboardCredentialsOK reports whether r carries DASHBOARD_TOKEN or the
+// DASHBOARD_USER and DASHBOARD_PASS pair.
+func dashboardCredentialsOK(r *http.Request) bool {
+	user, pass := os.Getenv("DASHBOARD_USER"), os.Getenv("DASHBOARD_PASS")
+	token := os.Getenv("DASHBOARD_TOKEN")
+	equal := func(got, want string) bool {
+		g, w := sha256.Sum256([]byte(got)), sha256.Sum256([]byte(want))
+		return subtle.ConstantTimeCompare(g[:], w[:]) == 1
+	}
+	if bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok && token != "" && equal(bearer, token) {
+		return true
+	}
+	u, p, ok := r.BasicAuth()
+	return ok && user != "" && pass != "" && equal(u, user) && equal(p, pass)
+}
+
+// dashboardAuth guards next when DASHBOARD_USER and DASHBOARD_PASS (HTTP
+// basic auth) or DASHBOARD_TOKEN (Authorization: Bearer, for scripts and
+// widgets) are set; either one lets a request through. With neither it
+// returns next unchanged.
+func dashboardAuth(next http.Handler) http.Handler {
+	user, pass := os.Getenv("DASHBOARD_USER"), os.Getenv("DASHBOARD_PASS")
+	token := os.Getenv("DASHBOARD_TOKEN")
+	useBasic := user != "" && pass != ""
+	if !useBasic && token == "" {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if dashboardCredentialsOK(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if useBasic {
+			w.Header().Set("WWW-Authenticate", `Basic realm="homepager", charset="UTF-8"`)
+		} else {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="homepager"`)
+		}
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	})
+}
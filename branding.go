@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// branding tells several open instances apart: DASHBOARD_TITLE,
+// FAVICON_URL and ACCENT_COLOR.
+type branding struct {
+	Title   string
+	Favicon template.URL
+	Accent  template.CSS // empty keeps the default look
+}
+
+// A hex color or a CSS color name; anything else could break out of the
+// style attribute.
+var accentPattern = regexp.MustCompile(`^(#[0-9a-fA-F]{3,8}|[a-zA-Z]+)$`)
+
+func loadBranding() (branding, error) {
+	b := branding{Title: strings.TrimSpace(os.Getenv("DASHBOARD_TITLE"))}
+	if accent := strings.TrimSpace(os.Getenv("ACCENT_COLOR")); accent != "" {
+		if !accentPattern.MatchString(accent) {
+			return b, fmt.Errorf("ACCENT_COLOR must be a hex color or a CSS color name, got %q", accent)
+		}
+		b.Accent = template.CSS(accent)
+	}
+	if icon := strings.TrimSpace(os.Getenv("FAVICON_URL")); icon != "" {
+		u, err := url.Parse(icon)
+		if err != nil || (u.Scheme != "" && u.Scheme != "http" && u.Scheme != "https") {
+			return b, fmt.Errorf("FAVICON_URL must be an http(s) URL or a path, got %q", icon)
+		}
+		b.Favicon = template.URL(icon)
+	} else if b.Accent != "" {
+		// Without an icon of its own, a dot in the accent color still
+		// sets the tab apart.
+		svg := fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 16 16"><circle cx="8" cy="8" r="7" fill="%s"/></svg>`, b.Accent)
+		b.Favicon = template.URL("data:image/svg+xml," + url.PathEscape(svg))
+	}
+	return b, nil
+}
+
+// title is DASHBOARD_TITLE, or "GitLab dashboard – user".
+func (b branding) title(user string) string {
+	if b.Title != "" {
+		return b.Title
+	}
+	return "GitLab dashboard – " + user
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// calEvent is an all-day event on its due date.
+type calEvent struct {
+	UID     string
+	Day     time.Time
+	Summary string
+	URL     string
+}
+
+// calendarEvents are the due dates of my issues and of the milestones my
+// issues and MRs are in. UIDs only depend on what the event is for, so a
+// moved due date moves the event instead of adding one.
+func calendarEvents(d dashboard) []calEvent {
+	var out []calEvent
+	seen := map[string]bool{}
+	milestone := func(host string, m *Milestone) {
+		if m == nil || m.DueDate == "" {
+			return
+		}
+		uid := fmt.Sprintf("milestone-%d@%s", m.ID, host)
+		day, err := time.Parse(time.DateOnly, m.DueDate)
+		if err != nil || seen[uid] {
+			return
+		}
+		seen[uid] = true
+		out = append(out, calEvent{UID: uid, Day: day, Summary: "Milestone " + m.Title + " due", URL: m.WebURL})
+	}
+	for _, i := range d.Issues {
+		if day, err := time.Parse(time.DateOnly, i.DueDate); err == nil {
+			out = append(out, calEvent{
+				UID:     fmt.Sprintf("issue-%d-%d@%s", i.ProjectID, i.IID, i.Host),
+				Day:     day,
+				Summary: i.Title + " (" + i.References.Full + ")",
+				URL:     i.WebURL,
+			})
+		}
+		milestone(i.Host, i.Milestone)
+	}
+	for _, m := range d.MRs {
+		if m.Provider == "" {
+			milestone(m.Host, m.Milestone)
+		}
+	}
+	return out
+}
+
+func writeCalendar(w io.Writer, name string, events []calEvent, now time.Time) {
+	lines := []string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//homepager//EN",
+		"CALSCALE:GREGORIAN",
+		"X-WR-CALNAME:" + icalText(name),
+	}
+	stamp := now.UTC().Format("20060102T150405Z")
+	for _, e := range events {
+		lines = append(lines,
+			"BEGIN:VEVENT",
+			"UID:"+icalText(e.UID),
+			"DTSTAMP:"+stamp,
+			"DTSTART;VALUE=DATE:"+e.Day.Format("20060102"),
+			"DTEND;VALUE=DATE:"+e.Day.AddDate(0, 0, 1).Format("20060102"),
+			"SUMMARY:"+icalText(e.Summary),
+		)
+		if e.URL != "" {
+			lines = append(lines, "URL:"+e.URL)
+		}
+		lines = append(lines, "END:VEVENT")
+	}
+	lines = append(lines, "END:VCALENDAR")
+	for _, l := range lines {
+		io.WriteString(w, icalFold(l)+"\r\n")
+	}
+}
+
+// icalText escapes a TEXT value (RFC 5545, 3.3.11).
+var icalText = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`).Replace
+
+// icalFold breaks lines longer than 75 octets, without splitting a UTF-8
+// sequence.
+func icalFold(l string) string {
+	var b strings.Builder
+	n := 0
+	for _, r := range l {
+		size := len(string(r))
+		if n+size > 75 {
+			b.WriteString("\r\n ")
+			n = 1
+		}
+		b.WriteRune(r)
+		n += size
+	}
+	return b.String()
+}
+
+// calendarHandler serves /calendar.ics for calendar apps to subscribe to.
+func calendarHandler(w http.ResponseWriter, r *http.Request) {
+	cfg, err := requestConfig(r)
+	if err != nil {
+		writeConfigError(w, r, err)
+		return
+	}
+	d, err := collectOrPolled(r.Context(), cfg, []string{"mine", "issues"})
+	if err != nil {
+		slog.Warn("dashboard incomplete", "err", errorHint(err))
+	}
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	writeCalendar(w, "GitLab – "+cfg.User, calendarEvents(d), time.Now())
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Instances older than this ignore include=head_pipeline, so sending it
+// only costs URL length; attachPipelines fetches the pipelines instead.
+var minHeadPipelineInclude = [2]int{12, 0}
+
+type hostCaps struct {
+	Version             string // empty when the probe failed
+	HeadPipelineInclude bool
+}
+
+// capabilities caches one probe per GITLAB_BASE for the process lifetime.
+// Concurrent callers share the probe in flight, which runs without mu
+// held so other hosts don't wait for it.
+var capabilities = struct {
+	mu       sync.Mutex
+	hosts    map[string]hostCaps
+	inflight map[string]*capsProbe
+}{hosts: map[string]hostCaps{}, inflight: map[string]*capsProbe{}}
+
+type capsProbe struct {
+	done chan struct{}
+	c    hostCaps
+}
+
+// hostCapabilities probes GET /api/v4/version once per host. A failed probe
+// assumes a current GitLab and is tried again next time.
+func hostCapabilities(ctx context.Context, h gitlabHost) hostCaps {
+	capabilities.mu.Lock()
+	if c, ok := capabilities.hosts[h.Base]; ok {
+		capabilities.mu.Unlock()
+		return c
+	}
+	if p, ok := capabilities.inflight[h.Base]; ok {
+		capabilities.mu.Unlock()
+		select {
+		case <-p.done:
+			return p.c
+		case <-ctx.Done():
+			return hostCaps{HeadPipelineInclude: true}
+		}
+	}
+	p := &capsProbe{done: make(chan struct{})}
+	capabilities.inflight[h.Base] = p
+	capabilities.mu.Unlock()
+
+	c, ok := probeVersion(ctx, h)
+	p.c = c
+	capabilities.mu.Lock()
+	if ok {
+		capabilities.hosts[h.Base] = c
+	}
+	delete(capabilities.inflight, h.Base)
+	capabilities.mu.Unlock()
+	close(p.done)
+	return c
+}
+
+// probeVersion asks the host for its version; ok is whether it answered.
+func probeVersion(ctx context.Context, h gitlabHost) (c hostCaps, ok bool) {
+	c = hostCaps{HeadPipelineInclude: true}
+	var v struct {
+		Version string `json:"version"`
+	}
+	if err := apiGet(ctx, h.Base+"/api/v4/version", h.Token, &v); err != nil {
+		slog.Warn("version probe failed, assuming a current GitLab", "host", h.Name, "err", errorHint(err))
+		return c, false
+	}
+	c.Version = v.Version
+	if major, minor, ok := parseVersion(v.Version); ok {
+		c.HeadPipelineInclude = major > minHeadPipelineInclude[0] ||
+			major == minHeadPipelineInclude[0] && minor >= minHeadPipelineInclude[1]
+	}
+	return c, true
+}
+
+// parseVersion reads major and minor from e.g. "16.4.1-ee".
+func parseVersion(v string) (major, minor int, ok bool) {
+	parts := strings.SplitN(v, ".", 3)
+	if len(parts) < 2 {
+		return 0, 0, false
+	}
+	major, err1 := strconv.Atoi(parts[0])
+	minor, err2 := strconv.Atoi(strings.TrimRightFunc(parts[1], func(r rune) bool { return r < '0' || r > '9' }))
+	return major, minor, err1 == nil && err2 == nil
+}
+
+// pipelineInclude is the query param asking for head_pipeline, or empty
+// when the host would ignore it.
+func pipelineInclude(ctx context.Context, h gitlabHost) string {
+	if hostCapabilities(ctx, h).HeadPipelineInclude {
+		return "&include=head_pipeline"
+	}
+	return ""
+}
+
+// probeHosts runs the probes at startup so the versions show up in the log.
+func probeHosts(cfg config) {
+	for _, h := range cfg.Hosts {
+		if h.Token == "" {
+			continue // OAuth: probed with the first visitor's token
+		}
+		c := hostCapabilities(context.Background(), h)
+		if c.Version != "" {
+			slog.Info("GitLab capabilities", "host", h.Name, "version", c.Version, "head_pipeline_include", c.HeadPipelineInclude)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHostCapabilitiesSharesProbe(t *testing.T) {
+	var probes atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		probes.Add(1)
+		time.Sleep(50 * time.Millisecond)
+		w.Write([]byte(`{"version":"11.9.0"}`))
+	}))
+	defer srv.Close()
+	h := gitlabHost{Base: srv.URL, Name: "shared"}
+
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Go(func() {
+			if c := hostCapabilities(context.Background(), h); c.Version != "11.9.0" || c.HeadPipelineInclude {
+				t.Errorf("got %+v", c)
+			}
+		})
+	}
+	wg.Wait()
+	hostCapabilities(context.Background(), h)
+	if n := probes.Load(); n != 1 {
+		t.Errorf("probed %d times, want 1", n)
+	}
+}
+
+func TestHostCapabilitiesRetriesFailedProbe(t *testing.T) {
+	var probes atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if probes.Add(1) == 1 {
+			http.Error(w, "down", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"version":"17.0.0"}`))
+	}))
+	defer srv.Close()
+	h := gitlabHost{Base: srv.URL, Name: "flaky"}
+
+	if c := hostCapabilities(context.Background(), h); c.Version != "" || !c.HeadPipelineInclude {
+		t.Errorf("failed probe: got %+v, want a current GitLab assumed", c)
+	}
+	if c := hostCapabilities(context.Background(), h); c.Version != "17.0.0" {
+		t.Errorf("second probe: got %+v", c)
+	}
+	hostCapabilities(context.Background(), h)
+	if n := probes.Load(); n != 2 {
+		t.Errorf("probed %d times, want 2", n)
+	}
+}
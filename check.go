@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// runCheck validates the configuration against every GitLab host and
+// prints one PASS/FAIL line per check. It returns the process exit code.
+func runCheck(out io.Writer) int {
+	cfg, err := loadConfig()
+	if err != nil {
+		fmt.Fprintf(out, "FAIL  config: %v\n", err)
+		return 1
+	}
+	ctx := context.Background()
+	failed := false
+	report := func(name string, err error) {
+		if err != nil {
+			failed = true
+			fmt.Fprintf(out, "FAIL  %s: %v\n", name, err)
+			return
+		}
+		fmt.Fprintf(out, "PASS  %s\n", name)
+	}
+	report("config", nil)
+	for _, h := range cfg.Hosts {
+		report(h.Name+": base URL", checkBase(ctx, h.Base))
+		report(h.Name+": token", checkToken(ctx, h, h.User))
+		for _, u := range cfg.TeamUsers {
+			report(h.Name+": teammate "+u, checkUser(ctx, h, u))
+		}
+	}
+	if failed {
+		return 1
+	}
+	return 0
+}
+
+func checkBase(ctx context.Context, base string) error {
+	u, err := url.Parse(base)
+	if err != nil {
+		return err
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%q is not an http(s) URL", base)
+	}
+	req, _ := http.NewRequestWithContext(ctx, "GET", base, nil)
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("unreachable: %w", err)
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func checkToken(ctx context.Context, h gitlabHost, username string) error {
+	var me struct {
+		Username string `json:"username"`
+	}
+	if err := apiGet(ctx, h.Base+"/api/v4/user", h.Token, &me); err != nil {
+		return err
+	}
+	if !strings.EqualFold(me.Username, username) {
+		return fmt.Errorf("token belongs to %q, GITLAB_USERNAME is %q", me.Username, username)
+	}
+	return nil
+}
+
+func checkUser(ctx context.Context, h gitlabHost, username string) error {
+	var users []struct {
+		Username string `json:"username"`
+	}
+	if err := apiGet(ctx, fmt.Sprintf("%s/api/v4/users?username=%s", h.Base, url.QueryEscape(username)), h.Token, &users); err != nil {
+		return err
+	}
+	if len(users) == 0 {
+		return fmt.Errorf("no such user")
+	}
+	return nil
+}
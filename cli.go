@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"text/tabwriter"
+)
+
+// runCLI collects the dashboard once and prints it as a table. It returns
+// the process exit code.
+func runCLI(out io.Writer) int {
+	cfg, err := loadConfig()
+	if err != nil {
+		slog.Error("config", "err", err)
+		return 2
+	}
+	d, err := collectDashboard(context.Background(), cfg)
+	printDashboard(out, d)
+	if err != nil {
+		slog.Error("dashboard incomplete", "err", errorHint(err))
+		return 1
+	}
+	return 0
+}
+
+func printDashboard(out io.Writer, d dashboard) {
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "TITLE\tPROJECT\tPIPELINE")
+	for _, m := range d.MRs {
+		status := "-"
+		if m.HeadPipeline != nil {
+			status = m.HeadPipeline.Status
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", m.Title, m.References.Full, status)
+	}
+	if len(d.Todos) > 0 {
+		fmt.Fprintln(tw, "\nTODO\tPROJECT\tACTION")
+		for _, t := range d.Todos {
+			fmt.Fprintf(tw, "%s\t%s\t%s\n", t.Target.Title, t.Project.Name, t.ActionName)
+		}
+	}
+	tw.Flush()
+	fmt.Fprintf(out, "\n%d open MRs, %d pending todos\n", len(d.MRs), len(d.Todos))
+}
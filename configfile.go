@@ -0,0 +1,158 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// fileConfig is config.yaml. Every key maps onto the env var of the same
+// setting, so loadConfig stays the single place that interprets them.
+type fileConfig struct {
+	GitLab struct {
+		Base     stringList `yaml:"base"`
+		Token    stringList `yaml:"token"`
+		Username stringList `yaml:"username"`
+	} `yaml:"gitlab"`
+	// Instances is the readable form of several comma-separated
+	// GITLAB_BASE/GITLAB_TOKEN/GITLAB_USERNAME entries.
+	Instances          []fileInstance `yaml:"instances"`
+	Teammates          stringList     `yaml:"teammates"`
+	Teams              []fileTeam     `yaml:"teams"`
+	TeamProjects       stringList     `yaml:"team_projects"`
+	Sections           stringList     `yaml:"sections"`
+	RefreshSeconds     *int           `yaml:"refresh_seconds"`
+	TimeRefreshSeconds *int           `yaml:"time_refresh_seconds"`
+
+	// Env sets any other variable by name, e.g. MR_LABELS.
+	Env map[string]string `yaml:"env"`
+}
+
+type fileInstance struct {
+	Base     string `yaml:"base"`
+	Token    string `yaml:"token"`
+	Username string `yaml:"username"`
+}
+
+type fileTeam struct {
+	Name  string     `yaml:"name"`
+	Users stringList `yaml:"users"`
+}
+
+// stringList accepts either a YAML list or a comma-separated string.
+type stringList []string
+
+func (l *stringList) UnmarshalYAML(n *yaml.Node) error {
+	if n.Kind == yaml.ScalarNode {
+		*l = splitUsers(n.Value)
+		return nil
+	}
+	var items []string
+	if err := n.Decode(&items); err != nil {
+		return err
+	}
+	*l = items
+	return nil
+}
+
+// loadConfigFile reads CONFIG_FILE (default config.yaml) into the
+// environment. Variables already set to something, from the shell or
+// .env, win over the file. A missing default file is fine.
+func loadConfigFile() error {
+	path, explicit := os.LookupEnv("CONFIG_FILE")
+	if !explicit {
+		path = "config.yaml"
+	}
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) && !explicit {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var fc fileConfig
+	dec := yaml.NewDecoder(f)
+	dec.KnownFields(true)
+	if err := dec.Decode(&fc); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	if err := fc.validate(); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	for name, value := range fc.env() {
+		if os.Getenv(name) == "" {
+			os.Setenv(name, value)
+		}
+	}
+	return nil
+}
+
+// validate catches instances that would leave GITLAB_BASE and
+// GITLAB_USERNAME with different counts once flattened.
+func (fc fileConfig) validate() error {
+	for i, in := range fc.Instances {
+		switch {
+		case strings.TrimSpace(in.Base) == "":
+			return fmt.Errorf("instances[%d]: base is missing", i)
+		case strings.TrimSpace(in.Username) == "":
+			return fmt.Errorf("instances[%d] (%s): username is missing", i, in.Base)
+		}
+	}
+	return nil
+}
+
+// env flattens the file into env var names and values; unset keys are
+// left out.
+func (fc fileConfig) env() map[string]string {
+	vars := map[string]string{}
+	list := func(name string, l stringList) {
+		if l != nil {
+			vars[name] = strings.Join(l, ",")
+		}
+	}
+	list("GITLAB_BASE", fc.GitLab.Base)
+	list("GITLAB_TOKEN", fc.GitLab.Token)
+	list("GITLAB_USERNAME", fc.GitLab.Username)
+	if len(fc.Instances) > 0 {
+		var bases, tokens, users stringList
+		for _, in := range fc.Instances {
+			bases = append(bases, in.Base)
+			tokens = append(tokens, in.Token)
+			users = append(users, in.Username)
+		}
+		list("GITLAB_BASE", bases)
+		list("GITLAB_USERNAME", users)
+		if slices.ContainsFunc(tokens, func(t string) bool { return t != "" }) {
+			list("GITLAB_TOKEN", tokens)
+		}
+	}
+	list("TEAMMATE_USERNAMES", fc.Teammates)
+	list("TEAM_PROJECTS", fc.TeamProjects)
+	list("SECTIONS", fc.Sections)
+	if len(fc.Teams) > 0 {
+		teams := make([]string, len(fc.Teams))
+		for i, t := range fc.Teams {
+			teams[i] = t.Name + ":" + strings.Join(t.Users, ",")
+		}
+		vars["TEAMS"] = strings.Join(teams, ";")
+	}
+	if fc.RefreshSeconds != nil {
+		vars["REFRESH_SECONDS"] = strconv.Itoa(*fc.RefreshSeconds)
+	}
+	if fc.TimeRefreshSeconds != nil {
+		vars["TIME_REFRESH_SECONDS"] = strconv.Itoa(*fc.TimeRefreshSeconds)
+	}
+	for name, value := range fc.Env {
+		vars[name] = value
+	}
+	return vars
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestConfigFileInstanceWithoutUsername(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	yaml := `instances:
+  - base: https://gitlab.com
+    username: jane
+  - base: https://gitlab.example.com
+`
+	if err := os.WriteFile(path, []byte(yaml), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("CONFIG_FILE", path)
+	err := loadConfigFile()
+	if err == nil || !strings.Contains(err.Error(), "instances[1] (https://gitlab.example.com): username is missing") {
+		t.Errorf("got %v, want the second instance named", err)
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import "time"
+
+type DateGroup struct {
+	Title string // message key, see catalogs
+	Todos []Todo
+}
+
+// bucketByDate groups todos by calendar day of CreatedAt in loc: today,
+// yesterday, the rest of this week (from Monday) and older. Empty groups
+// are dropped; order within a group is kept.
+func bucketByDate(todos []Todo, loc *time.Location) []DateGroup {
+	return bucketByDateAt(todos, time.Now().In(loc))
+}
+
+func bucketByDateAt(todos []Todo, now time.Time) []DateGroup {
+	y, m, d := now.Date()
+	today := time.Date(y, m, d, 0, 0, 0, 0, now.Location())
+	yesterday := today.AddDate(0, 0, -1)
+	weekStart := today.AddDate(0, 0, -(int(today.Weekday())+6)%7)
+
+	groups := []DateGroup{{Title: "date.today"}, {Title: "date.yesterday"}, {Title: "date.this_week"}, {Title: "date.older"}}
+	for _, t := range todos {
+		i := 3
+		switch c := t.CreatedAt.Time; {
+		case !c.Before(today):
+			i = 0
+		case !c.Before(yesterday):
+			i = 1
+		case !c.Before(weekStart):
+			i = 2
+		}
+		groups[i].Todos = append(groups[i].Todos, t)
+	}
+	out := groups[:0]
+	for _, g := range groups {
+		if len(g.Todos) > 0 {
+			out = append(out, g)
+		}
+	}
+	return out
+}
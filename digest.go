@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// Slack Block Kit, only the parts the digest uses.
+type slackMessage struct {
+	Text   string       `json:"text"` // notification fallback
+	Blocks []slackBlock `json:"blocks"`
+}
+
+type slackBlock struct {
+	Type string     `json:"type"`
+	Text *slackText `json:"text,omitempty"`
+}
+
+type slackText struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+var slackEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+func slackLink(url, title string) string {
+	return "<" + url + "|" + slackEscaper.Replace(title) + ">"
+}
+
+// slackDigest summarises the dashboard, listing MRs with a failed pipeline
+// first since those need action.
+func slackDigest(d dashboard) slackMessage {
+	s := dashboardStats(d)
+	summary := fmt.Sprintf("%d open MRs, %d pending todos, %d failing pipelines", s.OpenMRs, s.PendingTodos, s.FailingPipelines)
+	msg := slackMessage{
+		Text: "GitLab digest: " + summary,
+		Blocks: []slackBlock{
+			{Type: "header", Text: &slackText{"plain_text", "GitLab digest for " + d.User}},
+			{Type: "section", Text: &slackText{"mrkdwn", summary}},
+		},
+	}
+	var failing, rest []string
+	for _, m := range d.MRs {
+		line := "• " + slackLink(m.WebURL, m.Title) + " (" + slackEscaper.Replace(m.References.Full) + ")"
+		if m.HeadPipeline != nil && m.HeadPipeline.Status == "failed" {
+			failing = append(failing, ":red_circle: "+line+" – "+slackLink(m.HeadPipeline.WebURL, "pipeline failed"))
+		} else {
+			rest = append(rest, line)
+		}
+	}
+	// A section text is capped at 3000 characters by Slack.
+	addSection := func(title string, lines []string) {
+		if len(lines) == 0 {
+			return
+		}
+		text := "*" + title + "*"
+		for i, l := range lines {
+			if len(text)+len(l) > 2900 {
+				text += fmt.Sprintf("\n… and %d more", len(lines)-i)
+				break
+			}
+			text += "\n" + l
+		}
+		msg.Blocks = append(msg.Blocks, slackBlock{Type: "section", Text: &slackText{"mrkdwn", text}})
+	}
+	addSection("Failing pipelines", failing)
+	addSection("Open MRs", rest)
+	return msg
+}
+
+func postSlack(ctx context.Context, webhookURL string, msg slackMessage) error {
+	return postJSON(ctx, webhookURL, msg)
+}
+
+// sendDigest collects the dashboard from the env config and posts it to
+// SLACK_WEBHOOK_URL. Partial GitLab failures are logged, not fatal.
+func sendDigest(ctx context.Context) error {
+	webhook := os.Getenv("SLACK_WEBHOOK_URL")
+	if webhook == "" {
+		return errors.New("Set env var: SLACK_WEBHOOK_URL")
+	}
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	d, err := collectDashboard(ctx, cfg)
+	if err != nil {
+		slog.Warn("digest", "err", errorHint(err))
+	}
+	return postSlack(ctx, webhook, slackDigest(d))
+}
+
+// runDigest is the -digest mode, meant for cron. It returns the process
+// exit code.
+func runDigest() int {
+	if err := sendDigest(context.Background()); err != nil {
+		slog.Warn("digest", "err", errorHint(err))
+		return 1
+	}
+	return 0
+}
+
+// POST /digest does the same for setups where cron can only make HTTP
+// calls. It always uses the env token, also with OAuth enabled, so it
+// takes DASHBOARD_TOKEN (or DASHBOARD_USER and DASHBOARD_PASS) or a logged
+// in session, and is only served when DASHBOARD_* auth is set.
+func digestHandler(w http.ResponseWriter, r *http.Request) {
+	if !dashboardCredentialsOK(r) {
+		if cfg, err := requestConfig(r); err != nil || cfg.OAuth == nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+	}
+	if err := sendDigest(r.Context()); err != nil {
+		slog.Warn("digest", "err", errorHint(err))
+		http.Error(w, "could not send digest", http.StatusBadGateway)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
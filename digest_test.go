@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPostSlackErrorHidesPath(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "no_service", http.StatusNotFound)
+	}))
+	defer srv.Close()
+
+	err := postSlack(context.Background(), srv.URL+"/services/T000/B000/s3cr3t", slackMessage{Text: "hi"})
+	if err == nil {
+		t.Fatal("want an error for a 404")
+	}
+	if strings.Contains(err.Error(), "s3cr3t") {
+		t.Errorf("error leaks the webhook path: %v", err)
+	}
+	if !strings.Contains(err.Error(), strings.TrimPrefix(srv.URL, "http://")) {
+		t.Errorf("error does not name the host: %v", err)
+	}
+}
+
+func TestPostSlackUnreachableHidesPath(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	u := srv.URL + "/services/T000/B000/s3cr3t"
+	srv.Close()
+
+	err := postSlack(context.Background(), u, slackMessage{Text: "hi"})
+	if err == nil || strings.Contains(err.Error(), "s3cr3t") {
+		t.Errorf("want an error without the webhook path, got %v", err)
+	}
+}
+
+func TestDigestHandlerNeedsAuth(t *testing.T) {
+	t.Setenv("DASHBOARD_TOKEN", "s3cret")
+	for _, auth := range []string{"", "Bearer wrong", "Basic dXNlcjpwYXNz"} {
+		req := httptest.NewRequest("POST", "/digest", nil)
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		rec := httptest.NewRecorder()
+		digestHandler(rec, req)
+		if rec.Code != http.StatusUnauthorized {
+			t.Errorf("Authorization %q: got %d, want 401", auth, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+type discussion struct {
+	Notes []struct {
+		Resolvable bool `json:"resolvable"`
+		Resolved   bool `json:"resolved"`
+	} `json:"notes"`
+}
+
+// attachDiscussions counts unresolved threads per MR. It costs one request
+// per MR, so it only runs with FETCH_DISCUSSIONS=1.
+func attachDiscussions(ctx context.Context, base, token string, mrs []MR) []MR {
+	sem := make(chan struct{}, fetchWorkers)
+	var wg sync.WaitGroup
+	for i := range mrs {
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int) {
+			defer func() { <-sem; wg.Done() }()
+			var discussions []discussion
+			u := fmt.Sprintf("%s/api/v4/projects/%d/merge_requests/%d/discussions?per_page=100", base, mrs[i].ProjectID, mrs[i].IID)
+			if err := apiGet(ctx, u, token, &discussions); err != nil {
+				return
+			}
+			n := 0
+			for _, d := range discussions {
+				for _, note := range d.Notes {
+					if note.Resolvable && !note.Resolved {
+						n++
+						break
+					}
+				}
+			}
+			mrs[i].UnresolvedCount = n
+		}(i)
+	}
+	wg.Wait()
+	return mrs
+}
+
+// Discussions is the text of the discussion badge, empty if there's nothing
+// worth showing.
+func (m MR) Discussions() string {
+	switch {
+	case m.UnresolvedCount > 0:
+		return translate(uiLang(), "discussions.open_n", m.UnresolvedCount)
+	case m.BlockingDiscussionsResolved != nil && !*m.BlockingDiscussionsResolved:
+		return translate(uiLang(), "discussions.open")
+	case m.UserNotesCount > 0:
+		return fmt.Sprintf("💬 %d", m.UserNotesCount)
+	}
+	return ""
+}
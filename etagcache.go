@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"sync"
+)
+
+// etagTransport remembers GET responses that came with an ETag and sends
+// If-None-Match the next time, so an unchanged list costs GitLab a 304
+// instead of the full payload. Entries are per token, so visitors never
+// see each other's data. ETAG_CACHE_ENTRIES bounds the cache; 0 turns it
+// off.
+type etagTransport struct {
+	next http.RoundTripper
+	max  int
+
+	mu      sync.Mutex
+	entries map[string]*etagEntry
+	order   []string // insertion order, oldest first, for eviction
+}
+
+type etagEntry struct {
+	etag   string
+	header http.Header
+	body   []byte
+}
+
+// Bodies larger than this aren't kept.
+const etagMaxBody = 2 << 20
+
+func newETagTransport(next http.RoundTripper, max int) http.RoundTripper {
+	if max <= 0 {
+		return next
+	}
+	return &etagTransport{next: next, max: max, entries: map[string]*etagEntry{}}
+}
+
+func etagKey(req *http.Request) string {
+	h := sha256.New()
+	io.WriteString(h, req.Header.Get("Authorization"))
+	io.WriteString(h, "\x00")
+	io.WriteString(h, req.Header.Get("PRIVATE-TOKEN"))
+	return req.URL.String() + "#" + hex.EncodeToString(h.Sum(nil)[:8])
+}
+
+func (t *etagTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != "GET" || req.Header.Get("If-None-Match") != "" {
+		return t.next.RoundTrip(req)
+	}
+	key := etagKey(req)
+	t.mu.Lock()
+	cached := t.entries[key]
+	t.mu.Unlock()
+	if cached != nil {
+		req = req.Clone(req.Context())
+		req.Header.Set("If-None-Match", cached.etag)
+	}
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+	if cached != nil && resp.StatusCode == http.StatusNotModified {
+		resp.Body.Close()
+		clientCounters.NotModified.Add(1)
+		return &http.Response{
+			Status:        "200 OK",
+			StatusCode:    http.StatusOK,
+			Proto:         resp.Proto,
+			ProtoMajor:    resp.ProtoMajor,
+			ProtoMinor:    resp.ProtoMinor,
+			Header:        cached.header.Clone(),
+			Body:          io.NopCloser(bytes.NewReader(cached.body)),
+			ContentLength: int64(len(cached.body)),
+			Request:       req,
+		}, nil
+	}
+	etag := resp.Header.Get("ETag")
+	if resp.StatusCode != http.StatusOK || etag == "" {
+		return resp, nil
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, etagMaxBody+1))
+	if err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	if len(body) > etagMaxBody {
+		// Too big to keep: hand back what was read plus the rest.
+		resp.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(body), resp.Body), resp.Body}
+		return resp, nil
+	}
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	t.store(key, &etagEntry{etag: etag, header: resp.Header.Clone(), body: body})
+	return resp, nil
+}
+
+func (t *etagTransport) store(key string, e *etagEntry) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, ok := t.entries[key]; !ok {
+		t.order = append(t.order, key)
+	}
+	t.entries[key] = e
+	for len(t.order) > t.max {
+		delete(t.entries, t.order[0])
+		t.order = t.order[1:]
+	}
+}
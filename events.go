@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// liveUpdate is one event on /events: every section of the page, rendered
+// as on a full page load, for the page to swap in place.
+type liveUpdate struct {
+	Sections  map[string]string `json:"sections"` // name -> outer HTML
+	Failures  string            `json:"failures"` // banner HTML, empty when none
+	CaughtUp  bool              `json:"caught_up"`
+	PolledAt  time.Time         `json:"polled_at"`
+	TeamCount string            `json:"team_count"`
+}
+
+// eventsHandler streams a liveUpdate after every background poll, so the
+// page refreshes without a reload and keeps its scroll position and open
+// drawers. Without POLL_SECONDS there is nothing to stream; the page falls
+// back to REFRESH_SECONDS.
+func eventsHandler(w http.ResponseWriter, r *http.Request) {
+	if !polled.running() {
+		http.NotFound(w, r)
+		return
+	}
+	cfg, err := requestConfig(r)
+	if err != nil {
+		writeConfigError(w, r, err)
+		return
+	}
+	queryConfig(r, &cfg)
+	tmpl, err := pageTemplate()
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", 500)
+		return
+	}
+	updates, stop := polled.subscribe()
+	defer stop()
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Accel-Buffering", "no") // nginx: don't hold events back
+	fmt.Fprintf(w, "retry: %d\n\n", (polled.interval + 5*time.Second).Milliseconds())
+	flusher.Flush()
+	keepalive := time.NewTicker(30 * time.Second)
+	defer keepalive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-shuttingDown:
+			return
+		case <-keepalive.C:
+			fmt.Fprint(w, ": ping\n\n")
+		case <-updates:
+			u, err := renderLive(r, cfg, tmpl)
+			if err != nil {
+				slog.Error("events: render failed", "err", err)
+				continue
+			}
+			data, _ := json.Marshal(u)
+			fmt.Fprintf(w, "event: update\ndata: %s\n\n", data)
+		}
+		flusher.Flush()
+	}
+}
+
+func renderLive(r *http.Request, cfg config, tmpl *template.Template) (liveUpdate, error) {
+	d, err := collectOrPolled(r.Context(), cfg, cfg.Sections)
+	if err != nil {
+		slog.Warn("dashboard incomplete", "err", errorHint(err))
+	}
+	d = prepareDashboard(r, cfg, d, err)
+	u := liveUpdate{
+		Sections:  map[string]string{},
+		CaughtUp:  d.AllCaughtUp,
+		PolledAt:  d.PolledAt,
+		TeamCount: d.Counts.TeamMRs.String(),
+	}
+	var buf bytes.Buffer
+	if err := tmpl.ExecuteTemplate(&buf, "failures", d); err != nil {
+		return u, err
+	}
+	u.Failures = buf.String()
+	for _, name := range cfg.Sections {
+		buf.Reset()
+		if err := tmpl.ExecuteTemplate(&buf, name, d); err != nil {
+			return u, err
+		}
+		u.Sections[name] = buf.String()
+	}
+	return u, nil
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// exportHandler downloads my open MRs as CSV for spreadsheets.
+func exportHandler(w http.ResponseWriter, r *http.Request) {
+	cfg, err := requestConfig(r)
+	if err != nil {
+		writeConfigError(w, r, err)
+		return
+	}
+	queryConfig(r, &cfg)
+	d, err := collectOrPolled(r.Context(), cfg, []string{"mine"})
+	if err != nil {
+		slog.Warn("dashboard incomplete", "err", errorHint(err))
+	}
+	name := fmt.Sprintf("homepager-mrs-%s.csv", time.Now().In(cfg.Location).Format("2006-01-02"))
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+name+`"`)
+	_ = writeMRsCSV(w, d.MRs)
+}
+
+func writeMRsCSV(w io.Writer, mrs []MR) error {
+	cw := csv.NewWriter(w)
+	_ = cw.Write([]string{"title", "project", "author", "pipeline", "updated", "url"})
+	for _, m := range mrs {
+		status := ""
+		if m.HeadPipeline != nil {
+			status = m.HeadPipeline.Status
+		}
+		_ = cw.Write([]string{csvSafe(m.Title), m.References.Full, csvSafe(m.Author.Name), status, m.UpdatedAt.Format(time.RFC3339), m.WebURL})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// csvSafe keeps spreadsheets from evaluating a title such as "=HYPERLINK(…)"
+// as a formula.
+func csvSafe(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
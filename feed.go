@@ -0,0 +1,149 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+)
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Link    atomLink    `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+}
+
+type atomEntry struct {
+	ID      string     `xml:"id"`
+	Title   string     `xml:"title"`
+	Updated string     `xml:"updated"`
+	Link    atomLink   `xml:"link"`
+	Author  atomAuthor `xml:"author"`
+	Summary string     `xml:"summary,omitempty"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+// feedSeen remembers, per user, when each MR and todo first showed up on
+// the dashboard, so an entry is dated by when it appeared rather than by
+// its last activity. It only lives in memory.
+var feedSeen = struct {
+	sync.Mutex
+	byUser map[string]map[string]time.Time
+}{byUser: map[string]map[string]time.Time{}}
+
+// firstSeen is when each of the entry IDs was first seen; items maps them
+// to their own creation time. New ones are noted at now, except on the
+// user's first call, when everything is, so a restart doesn't date the
+// whole feed to it. A complete dashboard forgets what is gone.
+func firstSeen(user string, items map[string]time.Time, complete bool, now time.Time) map[string]time.Time {
+	feedSeen.Lock()
+	defer feedSeen.Unlock()
+	seen, ok := feedSeen.byUser[user]
+	if !ok {
+		seen = map[string]time.Time{}
+		feedSeen.byUser[user] = seen
+	}
+	for id, created := range items {
+		if _, known := seen[id]; known {
+			continue
+		}
+		if ok || created.IsZero() {
+			seen[id] = now
+		} else {
+			seen[id] = created
+		}
+	}
+	if complete {
+		for id := range seen {
+			if _, there := items[id]; !there {
+				delete(seen, id)
+			}
+		}
+	}
+	out := make(map[string]time.Time, len(items))
+	for id := range items {
+		out[id] = seen[id]
+	}
+	return out
+}
+
+// dashboardFeed has an entry for each of my MRs and todos, newest first,
+// dated when it first appeared. Entry IDs only depend on host, project and
+// IID (or todo ID) so feed readers recognise an entry across refreshes.
+func dashboardFeed(title string, d dashboard, complete bool, now time.Time) atomFeed {
+	f := atomFeed{
+		ID:    "urn:homepager:" + d.User,
+		Title: title,
+	}
+	items := map[string]time.Time{}
+	var entries []atomEntry
+	for _, m := range d.MRs {
+		e := atomEntry{
+			ID:      fmt.Sprintf("urn:homepager:%s:mr:%d:%d", m.Host, m.ProjectID, m.IID),
+			Title:   m.Title,
+			Link:    atomLink{Href: m.WebURL},
+			Author:  atomAuthor{Name: m.Author.Name},
+			Summary: m.References.Full,
+		}
+		items[e.ID] = m.CreatedAt.Time
+		entries = append(entries, e)
+	}
+	for _, t := range d.Todos {
+		e := atomEntry{
+			ID:      fmt.Sprintf("urn:homepager:%s:todo:%d", t.Host, t.ID),
+			Title:   fmt.Sprintf("[%s] %s", t.ActionName, t.Target.Title),
+			Link:    atomLink{Href: t.Target.WebURL},
+			Author:  atomAuthor{Name: t.Author.Name},
+			Summary: t.Project.Name,
+		}
+		items[e.ID] = t.CreatedAt.Time
+		entries = append(entries, e)
+	}
+	seen := firstSeen(d.User, items, complete, now)
+	slices.SortStableFunc(entries, func(a, b atomEntry) int { return seen[b.ID].Compare(seen[a.ID]) })
+	latest := now
+	if len(entries) > 0 {
+		latest = seen[entries[0].ID]
+	}
+	for i := range entries {
+		entries[i].Updated = seen[entries[i].ID].UTC().Format(time.RFC3339)
+	}
+	f.Entries = entries
+	f.Updated = latest.UTC().Format(time.RFC3339)
+	return f
+}
+
+func feedHandler(w http.ResponseWriter, r *http.Request) {
+	cfg, err := requestConfig(r)
+	if err != nil {
+		writeConfigError(w, r, err)
+		return
+	}
+	d, err := collectOrPolled(r.Context(), cfg, cfg.Sections)
+	if err != nil {
+		slog.Warn("dashboard incomplete", "err", errorHint(err))
+	}
+	f := dashboardFeed(cfg.Branding.title(d.User), d, err == nil, time.Now())
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	f.Link = atomLink{Href: scheme + "://" + r.Host + "/", Rel: "alternate"}
+	w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+	_, _ = w.Write([]byte(xml.Header))
+	_ = xml.NewEncoder(w).Encode(f)
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDashboardFeedFirstSeen(t *testing.T) {
+	t0 := time.Date(2026, 1, 1, 9, 0, 0, 0, time.UTC)
+	created := t0.Add(-48 * time.Hour)
+	mr := func(iid int) MR {
+		m := MR{IID: iid, ProjectID: 1, Host: "gitlab.example.com", Title: "mr"}
+		m.CreatedAt.Time = created
+		return m
+	}
+	d := dashboard{User: "feedtest", MRs: []MR{mr(1)}}
+
+	f := dashboardFeed("My dashboard", d, true, t0)
+	if f.Title != "My dashboard" {
+		t.Errorf("title = %q", f.Title)
+	}
+	if len(f.Entries) != 1 || f.Entries[0].Updated != created.Format(time.RFC3339) {
+		t.Fatalf("first look should date entries by creation, got %+v", f.Entries)
+	}
+
+	t1 := t0.Add(time.Hour)
+	d.MRs = append(d.MRs, mr(2))
+	f = dashboardFeed("My dashboard", d, true, t1)
+	if len(f.Entries) != 2 {
+		t.Fatalf("got %d entries, want 2", len(f.Entries))
+	}
+	if e := f.Entries[0]; e.ID != "urn:homepager:gitlab.example.com:mr:1:2" || e.Updated != t1.Format(time.RFC3339) {
+		t.Errorf("the new MR should come first, dated when seen: %+v", e)
+	}
+	if f.Entries[1].Updated != created.Format(time.RFC3339) {
+		t.Errorf("a known MR moved: %+v", f.Entries[1])
+	}
+	if f.Updated != t1.Format(time.RFC3339) {
+		t.Errorf("feed updated = %s, want %s", f.Updated, t1.Format(time.RFC3339))
+	}
+
+	// An incomplete dashboard must not forget MR 1, or it would come back as new.
+	d.MRs = d.MRs[1:]
+	dashboardFeed("My dashboard", d, false, t1.Add(time.Hour))
+	d.MRs = []MR{mr(1), mr(2)}
+	f = dashboardFeed("My dashboard", d, true, t1.Add(2*time.Hour))
+	if f.Entries[1].Updated != created.Format(time.RFC3339) {
+		t.Errorf("MR 1 was forgotten after a partial load: %+v", f.Entries[1])
+	}
+}
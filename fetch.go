@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+)
+
+// Stop following pages after this many (2000 items at per_page=100) so a
+// huge instance can't stall a page load; set once from MAX_PAGES.
+var maxPages = 20
+
+func configurePagination() error {
+	n, err := envInt("MAX_PAGES", maxPages)
+	if err != nil {
+		return err
+	}
+	if n < 1 {
+		return fmt.Errorf("MAX_PAGES must be a positive integer, got %d", n)
+	}
+	maxPages = n
+	return nil
+}
+
+// fetch GETs a GitLab list endpoint and follows its pagination, returning
+// everything decoded as []T. On error the pages fetched so far are
+// returned along with it.
+func fetch[T any](ctx context.Context, client *http.Client, rawURL, token string) ([]T, error) {
+	var all []T
+	u := rawURL
+	for range maxPages {
+		items, next, err := fetchPage[T](ctx, client, u, token)
+		all = append(all, items...)
+		if err != nil || next == "" {
+			return all, err
+		}
+		u = next
+	}
+	return all, nil
+}
+
+// fetchPage GETs a single page. next is the URL of the following page,
+// empty on the last one.
+func fetchPage[T any](ctx context.Context, client *http.Client, url, token string) (items []T, next string, err error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	setAuth(req, token)
+	req.Header.Set("Accept", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return nil, "", newAPIError(url, resp)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&items); err != nil {
+		return nil, "", err
+	}
+	next, err = nextPage(url, resp.Header)
+	return items, next, err
+}
+
+var linkNext = regexp.MustCompile(`<([^>]+)>;\s*rel="next"`)
+
+// nextPage reads X-Next-Page, which offset pagination sends, and falls
+// back to the Link header: GitLab drops X-Next-Page past 10,000 items and
+// keyset pagination only has Link.
+func nextPage(rawURL string, h http.Header) (string, error) {
+	if page := h.Get("X-Next-Page"); page != "" {
+		return withPage(rawURL, page)
+	}
+	if m := linkNext.FindStringSubmatch(h.Get("Link")); m != nil {
+		return m[1], nil
+	}
+	return "", nil
+}
+
+func withPage(rawURL, page string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+	q := u.Query()
+	q.Set("page", page)
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
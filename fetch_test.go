@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+)
+
+type item struct {
+	N int `json:"n"`
+}
+
+// pagedServer serves pages of one item each, up to last, announcing the
+// next page the way paging says: "x-next-page" or "link".
+func pagedServer(t *testing.T, paging string, last int) (*httptest.Server, *atomic.Int32) {
+	requests := new(atomic.Int32)
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		if page == 0 {
+			page = 1
+		}
+		if page < last {
+			switch paging {
+			case "x-next-page":
+				w.Header().Set("X-Next-Page", strconv.Itoa(page+1))
+			case "link":
+				w.Header().Set("Link", fmt.Sprintf(`<%s/items?page=%d>; rel="next", <%s/items?page=1>; rel="first"`, srv.URL, page+1, srv.URL))
+			}
+		}
+		fmt.Fprintf(w, `[{"n":%d}]`, page)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, requests
+}
+
+func TestFetchPagination(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		paging   string
+		last     int
+		maxPages int
+		want     int // items
+	}{
+		{"X-Next-Page", "x-next-page", 3, 20, 3},
+		{"Link header", "link", 4, 20, 4},
+		{"single page", "none", 5, 20, 1},
+		{"MAX_PAGES cutoff", "x-next-page", 50, 5, 5},
+		{"MAX_PAGES cutoff with Link", "link", 50, 2, 2},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			old := maxPages
+			maxPages = tt.maxPages
+			defer func() { maxPages = old }()
+			srv, requests := pagedServer(t, tt.paging, tt.last)
+
+			items, err := fetch[item](context.Background(), http.DefaultClient, srv.URL+"/items?per_page=1", "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n := int(requests.Load()); len(items) != tt.want || n != tt.want {
+				t.Fatalf("got %d items in %d requests, want %d", len(items), n, tt.want)
+			}
+			for i, it := range items {
+				if it.N != i+1 {
+					t.Errorf("item %d is from page %d", i, it.N)
+				}
+			}
+		})
+	}
+}
+
+func TestFetchKeepsPagesOnError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("page") == "2" {
+			http.Error(w, "boom", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("X-Next-Page", "2")
+		w.Write([]byte(`[{"n":1}]`))
+	}))
+	defer srv.Close()
+	items, err := fetch[item](context.Background(), http.DefaultClient, srv.URL+"/items", "")
+	if err == nil || len(items) != 1 {
+		t.Errorf("got %d items, err %v; want the first page and an error", len(items), err)
+	}
+}
+
+func TestNextPage(t *testing.T) {
+	h := http.Header{}
+	h.Set("X-Next-Page", "3")
+	h.Set("Link", `<https://other/next>; rel="next"`)
+	got, err := nextPage("https://gitlab.example.com/api/v4/todos?state=pending&page=2", h)
+	if err != nil || got != "https://gitlab.example.com/api/v4/todos?page=3&state=pending" {
+		t.Errorf("X-Next-Page wins: got %q, %v", got, err)
+	}
+	if got, _ := nextPage("https://x/y", http.Header{}); got != "" {
+		t.Errorf("no headers: got %q", got)
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Every setting is an env var; these flags set them for one run without
+// editing .env. A flag sets the variable before .env and config.yaml are
+// read, and neither overrides what is already set, so the precedence is
+// flags > environment and .env > config file.
+var envFlags = []struct{ name, env, usage string }{
+	{"base", "GITLAB_BASE", "GitLab URL(s), comma-separated"},
+	{"user", "GITLAB_USERNAME", "GitLab username(s)"},
+	{"token", "GITLAB_TOKEN", "GitLab token(s); visible in ps, prefer -token-file"},
+	{"team", "TEAMMATE_USERNAMES", "teammates' usernames, comma-separated"},
+	{"sections", "SECTIONS", "sections to show, in order"},
+	{"port", "PORT", "port to listen on"},
+	{"config", "CONFIG_FILE", "config file to read; default config.yaml"},
+}
+
+func registerEnvFlags() {
+	for _, f := range envFlags {
+		flag.Func(f.name, f.usage+" ("+f.env+")", func(v string) error {
+			return os.Setenv(f.env, v)
+		})
+	}
+	flag.Func("token-file", "read GITLAB_TOKEN from this file", func(path string) error {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return os.Setenv("GITLAB_TOKEN", strings.TrimSpace(string(b)))
+	})
+	flag.Func("env", "set any env var, as NAME=value; repeatable", func(v string) error {
+		name, value, ok := strings.Cut(v, "=")
+		if !ok || name == "" {
+			return errors.New("want NAME=value")
+		}
+		if err := os.Setenv(name, value); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// githubConfig adds open GitHub pull requests to "mine". GITHUB_TOKEN
+// turns it on; GITHUB_API points at a GitHub Enterprise instance.
+type githubConfig struct {
+	API   string // e.g. https://api.github.com
+	Token string
+	Name  string // host shown on the badge
+}
+
+func loadGitHubConfig() (*githubConfig, error) {
+	token, err := secretEnv("GITHUB_TOKEN")
+	if token == "" || err != nil {
+		return nil, err
+	}
+	api := strings.TrimRight(os.Getenv("GITHUB_API"), "/")
+	if api == "" {
+		api = "https://api.github.com"
+	}
+	u, err := url.Parse(api)
+	if err != nil || u.Host == "" {
+		return nil, errors.New("GITHUB_API must be an absolute URL")
+	}
+	name := strings.TrimPrefix(u.Host, "api.")
+	return &githubConfig{API: api, Token: token, Name: name}, nil
+}
+
+// githubIssue is a search result; pull requests come back as issues.
+type githubIssue struct {
+	ID        int       `json:"id"`
+	Number    int       `json:"number"`
+	Title     string    `json:"title"`
+	HTMLURL   string    `json:"html_url"`
+	CreatedAt Timestamp `json:"created_at"`
+	UpdatedAt Timestamp `json:"updated_at"`
+	User      struct {
+		Login     string `json:"login"`
+		AvatarURL string `json:"avatar_url"`
+	} `json:"user"`
+	Labels []struct {
+		Name string `json:"name"`
+	} `json:"labels"`
+	RepositoryURL string `json:"repository_url"` // .../repos/owner/name
+	Comments      int    `json:"comments"`
+}
+
+// mr maps a pull request onto the MR card. GitHub has no project IDs in
+// search results, so Key falls back to the owner/name#number reference.
+func (i githubIssue) mr(host string) MR {
+	m := MR{
+		Host:      host,
+		Provider:  "github",
+		ID:        i.ID,
+		IID:       i.Number,
+		Title:     i.Title,
+		WebURL:    i.HTMLURL,
+		CreatedAt: i.CreatedAt,
+		UpdatedAt: i.UpdatedAt,
+		Author:    Author{Username: i.User.Login, Name: i.User.Login, AvatarURL: i.User.AvatarURL},
+
+		UserNotesCount: i.Comments,
+	}
+	_, repo, _ := strings.Cut(i.RepositoryURL, "/repos/")
+	m.References.Full = repo + "#" + strconv.Itoa(i.Number)
+	for _, l := range i.Labels {
+		m.Labels = append(m.Labels, l.Name)
+	}
+	return m
+}
+
+// collectGitHub returns open pull requests assigned to me or waiting for
+// my review.
+func collectGitHub(ctx context.Context, cfg config, g githubConfig) ([]MR, error) {
+	filter := githubQualifiers(cfg)
+	assigned, errA := githubSearch(ctx, g, "is:pr is:open archived:false assignee:@me"+filter)
+	requested, errR := githubSearch(ctx, g, "is:pr is:open archived:false review-requested:@me"+filter)
+	var mrs []MR
+	for _, i := range requested {
+		m := i.mr(g.Name)
+		// GitHub drops the request once I review, so a pending one
+		// means I haven't looked yet.
+		m.ReviewState = "unreviewed"
+		m.FromTeam = containsUser(cfg.TeamUsers, m.Author.Username)
+		mrs = append(mrs, m)
+	}
+	for _, i := range assigned {
+		mrs = append(mrs, i.mr(g.Name))
+	}
+	mrs = filterByAge(uniqMRs(mrs), cfg.ageCutoff())
+	for i := range mrs {
+		mrs[i].ProjectColor = projectColor(projectPath(mrs[i].References.Full))
+	}
+	return mrs, errors.Join(tagError("part.assigned", g.Name, errA), tagError("part.reviewer", g.Name, errR))
+}
+
+// githubQualifiers is mrFilter in search syntax. GitLab's special
+// milestones other than None have no GitHub equivalent and are ignored.
+func githubQualifiers(cfg config) string {
+	var f string
+	for _, l := range cfg.Labels {
+		f += ` label:"` + l + `"`
+	}
+	switch m := milestoneParam(cfg.Milestone); m {
+	case "", "Any", "Upcoming", "Started":
+	case "None":
+		f += " no:milestone"
+	default:
+		f += ` milestone:"` + m + `"`
+	}
+	return f
+}
+
+// githubClient talks to GitHub. It is built from GitHub's settings, so
+// GITLAB_INSECURE and GitLab's metrics and ETag cache stay out of it.
+var githubClient = &http.Client{Timeout: 10 * time.Second}
+
+// newGitHubClient honours the proxy env vars and, with GITHUB_INSECURE=1,
+// skips TLS verification for a self-signed GitHub Enterprise.
+func newGitHubClient() *http.Client {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.Proxy = http.ProxyFromEnvironment
+	if envBool("GITHUB_INSECURE") {
+		slog.Warn("GITHUB_INSECURE is set, TLS certificates of GitHub are NOT verified")
+		t.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+	return &http.Client{Timeout: 10 * time.Second, Transport: newRetryTransport(t)}
+}
+
+// githubSearch runs an issue search, following the Link header for up to
+// maxPages pages.
+func githubSearch(ctx context.Context, g githubConfig, query string) ([]githubIssue, error) {
+	var all []githubIssue
+	u := g.API + "/search/issues?per_page=100&q=" + url.QueryEscape(query)
+	for range maxPages {
+		req, err := http.NewRequestWithContext(ctx, "GET", u, nil)
+		if err != nil {
+			return all, err
+		}
+		req.Header.Set("Authorization", "Bearer "+g.Token)
+		req.Header.Set("Accept", "application/vnd.github+json")
+		req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+		resp, err := githubClient.Do(req)
+		if err != nil {
+			return all, err
+		}
+		var page struct {
+			Items []githubIssue `json:"items"`
+		}
+		if resp.StatusCode >= 300 {
+			err = newAPIError(u, resp)
+		} else {
+			err = json.NewDecoder(resp.Body).Decode(&page)
+		}
+		resp.Body.Close()
+		all = append(all, page.Items...)
+		if err != nil {
+			return all, err
+		}
+		next := linkNext.FindStringSubmatch(resp.Header.Get("Link"))
+		if next == nil {
+			return all, nil
+		}
+		u = next[1]
+	}
+	return all, nil
+}
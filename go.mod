@@ -2,4 +2,17 @@ module github.com/bad33ndj3/homepager
 
 go 1.25.1
 
-require github.com/joho/godotenv v1.5.1 // indirect
+require (
+	github.com/coder/websocket v1.8.15
+	github.com/joho/godotenv v1.5.1
+	golang.org/x/crypto v0.50.0
+	golang.org/x/sync v0.20.0
+	golang.org/x/term v0.42.0
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require (
+	golang.org/x/net v0.52.0 // indirect
+	golang.org/x/sys v0.43.0 // indirect
+	golang.org/x/text v0.36.0 // indirect
+)
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// upstream remembers how the last GitLab calls went, for /readyz. It is
+// fed by metricsTransport, so page loads, the poller and the probe below
+// all count; responses from hosts other than GITLAB_BASE's, like webhooks,
+// don't.
+var upstream struct {
+	sync.Mutex
+	ok         time.Time // last 2xx or 304
+	authFailed time.Time // last 401
+}
+
+func noteUpstream(code int) {
+	upstream.Lock()
+	defer upstream.Unlock()
+	switch {
+	case code < 400:
+		upstream.ok = time.Now()
+	case code == http.StatusUnauthorized:
+		upstream.authFailed = time.Now()
+	}
+}
+
+// GET /healthz: the process is up and serving.
+func healthzHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprintln(w, "ok")
+}
+
+// GET /readyz: GitLab answered within READY_MAX_AGE_MINUTES (default 10)
+// and the token was not rejected since. When nothing was fetched lately,
+// e.g. no poller and no visitors, each host is asked for the token's user
+// there and then.
+func readyzHandler(w http.ResponseWriter, r *http.Request) {
+	mins, err := envInt("READY_MAX_AGE_MINUTES", 10)
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	upstream.Lock()
+	ok, authFailed := upstream.ok, upstream.authFailed
+	upstream.Unlock()
+	if ok.After(authFailed) && time.Since(ok) < time.Duration(mins)*time.Minute {
+		fmt.Fprintf(w, "ok: GitLab answered %s ago\n", time.Since(ok).Round(time.Second))
+		return
+	}
+	cfg, err := loadConfig()
+	if err != nil {
+		http.Error(w, "not ready: "+err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	if err := checkHosts(ctx, cfg); err != nil {
+		slog.Warn("not ready", "err", errorHint(err))
+		http.Error(w, "not ready: "+err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	fmt.Fprintln(w, "ok")
+}
+
+// checkHosts checks every GitLab host with its token. With the OAuth
+// login there is no token of our own; any answer from the host will do.
+func checkHosts(ctx context.Context, cfg config) error {
+	for _, h := range cfg.Hosts {
+		if cfg.OAuth != nil {
+			req, _ := http.NewRequestWithContext(ctx, "HEAD", h.Base+"/api/v4/version", nil)
+			resp, err := httpClient.Do(req)
+			if err != nil {
+				return err
+			}
+			resp.Body.Close()
+			continue
+		}
+		var u struct {
+			Username string `json:"username"`
+		}
+		if err := apiGet(ctx, h.Base+"/api/v4/user", h.Token, &u); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+type statusTransport int
+
+func (s statusTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return &http.Response{StatusCode: int(s), Body: http.NoBody, Request: req}, nil
+}
+
+func TestNoteUpstreamOnlyGitLab(t *testing.T) {
+	upstream.Lock()
+	upstream.ok, upstream.authFailed = time.Time{}, time.Time{}
+	upstream.Unlock()
+	client := &http.Client{Transport: &metricsTransport{statusTransport(401), map[string]bool{"gitlab.example.com": true}}}
+
+	resp, err := client.Get("https://hooks.slack.com/services/T0/B0/secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	upstream.Lock()
+	failed := upstream.authFailed
+	upstream.Unlock()
+	if !failed.IsZero() {
+		t.Fatal("a 401 from a webhook counted as GitLab rejecting the token")
+	}
+
+	resp, err = client.Get("https://gitlab.example.com/api/v4/user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	upstream.Lock()
+	failed = upstream.authFailed
+	upstream.Unlock()
+	if failed.IsZero() {
+		t.Fatal("a 401 from GitLab was not noted")
+	}
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// catalogs maps language -> message key -> text. Keys missing from a
+// catalog fall back to English.
+var catalogs = map[string]map[string]string{
+	"en": {
+		"search.placeholder":   "Filter by title…",
+		"logged_in_as":         "Logged in as",
+		"logout":               "Log out",
+		"theme.toggle":         "Toggle light/dark theme",
+		"refresh.every":        "Auto-refresh every %ds",
+		"refresh.off":          "Auto-refresh off",
+		"refresh.live":         "Live updates",
+		"team.title":           "Team MRs",
+		"team.median_age":      "median age",
+		"team.older_than_week": "older than a week",
+		"team.review":          "review",
+		"team.empty":           "No team MRs.",
+		"team.source":          "Source: authors or assignees from",
+		"team.source_personal": "your own list",
+		"push.off":             "🔔 Notify me",
+		"push.on":              "🔕 Stop notifications",
+		"push.denied":          "Notifications blocked",
+		"team.mine":            "My teammates",
+		"team.save":            "Save",
+		"team.select":          "Show team",
+		"caught_up.title":      "You’re all caught up",
+		"caught_up.body":       "No open MRs, issues or todos.",
+		"footer.tip":           "Tip: click a card to open it in a new tab.",
+		"footer.keys":          "Keys: j/k to move, Enter to open.",
+		"mine.title":           "Open Merge Requests",
+		"mine.empty":           "No open MRs.",
+		"approved.title":       "Approved by me, not yet merged",
+		"approved.empty":       "Nothing approved is waiting to be merged.",
+		"issues.title":         "My Issues",
+		"issues.empty":         "No open issues.",
+		"todos.title":          "Todos",
+		"todos.filtered_on":    "filtered on",
+		"todos.only":           "Only show %s",
+		"todos.created":        "created",
+		"todos.done":           "✓ done",
+		"todos.done_title":     "Mark as done",
+		"todos.empty":          "No open todos.",
+		"todos.action":         "Action required",
+		"todos.info":           "For your information",
+		"show_all":             "show all",
+		"by":                   "by",
+		"updated":              "last updated",
+		"discussions":          "discussions",
+		"badge.team_title":     "Review request from a teammate",
+		"badge.team":           "team",
+		"heading":              "GitLab dashboard",
+		"host":                 "Host",
+		"mine.roles":           "assignee + reviewer",
+		"milestone":            "milestone",
+		"merge.title":          "merge status",
+		"merge.mergeable":      "mergeable",
+		"merge.checking":       "checking",
+		"merge.blocked":        "blocked",
+		"discussions.open_n":   "💬 %d unresolved",
+		"discussions.open":     "💬 unresolved",
+		"snooze":               "snooze",
+		"snooze.title":         "Hide this MR for a while",
+		"snooze.duration":      "Snooze duration",
+		"snooze.4h":            "4 hours",
+		"snooze.1d":            "1 day",
+		"snooze.1w":            "1 week",
+		"snooze.until":         "until…",
+		"snooze.until_in":      "Snooze until, time zone %s",
+		"snooze.count":         "%d snoozed • show all",
+		"snooze.show_all":      "Show all snoozed MRs again",
+		"group.first_look":     "Awaiting my first look",
+		"group.in_progress":    "In progress",
+		"date.today":           "Today",
+		"date.yesterday":       "Yesterday",
+		"date.this_week":       "This week",
+		"date.older":           "Older",
+		"section.error":        "Could not load this section.",
+		"errors.title":         "Some of your data could not be loaded; what you see may be incomplete.",
+		"errors.failed":        "%s failed",
+		"part.assigned":        "Assigned MRs",
+		"part.reviewer":        "Reviewer MRs",
+		"part.mine":            "My MRs",
+		"part.team":            "Team MRs",
+		"part.approved":        "Approved MRs",
+		"part.issues":          "Issues",
+		"part.todos":           "Todos",
+		"part.other":           "Loading",
+		"pipeline.unknown":     "Pipeline status could not be loaded",
+		"pipeline.status":      "pipeline: %s",
+		"pipeline.running":     "pipeline: %s for %s",
+		"pipeline.finished":    "pipeline: %s in %s, %s ago",
+	},
+	"nl": {
+		"search.placeholder":   "Filter op titel…",
+		"logged_in_as":         "Ingelogd als",
+		"logout":               "Uitloggen",
+		"theme.toggle":         "Wissel licht/donker thema",
+		"refresh.every":        "Auto-refresh elke %ds",
+		"refresh.off":          "Auto-refresh uit",
+		"refresh.live":         "Live bijgewerkt",
+		"team.title":           "Team MR’s",
+		"team.median_age":      "mediane leeftijd",
+		"team.older_than_week": "ouder dan een week",
+		"team.review":          "review",
+		"team.empty":           "Geen team-MR’s.",
+		"team.source":          "Bron: auteurs of assignees uit",
+		"team.source_personal": "je eigen lijst",
+		"push.off":             "🔔 Meldingen aan",
+		"push.on":              "🔕 Meldingen uit",
+		"push.denied":          "Meldingen geblokkeerd",
+		"team.mine":            "Mijn teamgenoten",
+		"team.save":            "Opslaan",
+		"team.select":          "Toon team",
+		"caught_up.title":      "Je bent helemaal bij",
+		"caught_up.body":       "Geen open MR’s, issues of todos.",
+		"footer.tip":           "Tip: klik op een kaart om in een nieuw tabblad te openen.",
+		"footer.keys":          "Toetsen: j/k om te bewegen, Enter om te openen.",
+		"mine.title":           "Open merge requests",
+		"mine.empty":           "Geen open MR’s.",
+		"approved.title":       "Door mij goedgekeurd, nog niet gemerged",
+		"approved.empty":       "Niets goedgekeurds wacht op een merge.",
+		"issues.title":         "Mijn issues",
+		"issues.empty":         "Geen open issues.",
+		"todos.title":          "Todo’s",
+		"todos.filtered_on":    "gefilterd op",
+		"todos.only":           "Alleen %s tonen",
+		"todos.created":        "aangemaakt",
+		"todos.done":           "✓ klaar",
+		"todos.done_title":     "Markeer als klaar",
+		"todos.empty":          "Geen open todos.",
+		"todos.action":         "Actie nodig",
+		"todos.info":           "Ter informatie",
+		"show_all":             "alles tonen",
+		"by":                   "door",
+		"updated":              "laatst geüpdatet",
+		"discussions":          "discussies",
+		"badge.team_title":     "Review-verzoek van een teamgenoot",
+		"badge.team":           "team",
+		"heading":              "GitLab-dashboard",
+		"host":                 "Host",
+		"mine.roles":           "toegewezen + reviewer",
+		"milestone":            "mijlpaal",
+		"merge.title":          "mergestatus",
+		"merge.mergeable":      "mergebaar",
+		"merge.checking":       "wordt gecontroleerd",
+		"merge.blocked":        "geblokkeerd",
+		"discussions.open_n":   "💬 %d onopgelost",
+		"discussions.open":     "💬 onopgelost",
+		"snooze":               "snooze",
+		"snooze.title":         "Verberg deze MR tijdelijk",
+		"snooze.duration":      "Snooze duur",
+		"snooze.4h":            "4 uur",
+		"snooze.1d":            "1 dag",
+		"snooze.1w":            "1 week",
+		"snooze.until":         "tot…",
+		"snooze.until_in":      "Snooze tot, tijdzone %s",
+		"snooze.count":         "%d gesnoozed • alles tonen",
+		"snooze.show_all":      "Toon alle gesnoozede MR’s weer",
+		"group.first_look":     "Wacht op mijn eerste blik",
+		"group.in_progress":    "Bezig",
+		"date.today":           "Vandaag",
+		"date.yesterday":       "Gisteren",
+		"date.this_week":       "Deze week",
+		"date.older":           "Ouder",
+		"section.error":        "Deze sectie kon niet worden geladen.",
+		"errors.title":         "Niet alles kon worden geladen; wat je ziet is mogelijk onvolledig.",
+		"errors.failed":        "%s mislukt",
+		"part.assigned":        "Toegewezen MR’s",
+		"part.reviewer":        "MR’s om te reviewen",
+		"part.mine":            "Mijn MR’s",
+		"part.team":            "Team-MR’s",
+		"part.approved":        "Goedgekeurde MR’s",
+		"part.issues":          "Issues",
+		"part.todos":           "Todo’s",
+		"part.other":           "Laden",
+		"pipeline.unknown":     "Pipelinestatus kon niet worden geladen",
+		"pipeline.status":      "pipeline: %s",
+		"pipeline.running":     "pipeline: %s, al %s bezig",
+		"pipeline.finished":    "pipeline: %s in %s, %s geleden",
+	},
+}
+
+// uiLang reads LANG, which may be a full locale such as nl_NL.UTF-8.
+// Unknown languages get English.
+func uiLang() string {
+	lang := strings.ToLower(os.Getenv("LANG"))
+	if i := strings.IndexAny(lang, "_-."); i >= 0 {
+		lang = lang[:i]
+	}
+	if _, ok := catalogs[lang]; !ok {
+		return "en"
+	}
+	return lang
+}
+
+// translate looks key up in lang, then English; an unknown key is
+// returned as is. Args are applied with fmt.Sprintf.
+func translate(lang, key string, args ...any) string {
+	msg, ok := catalogs[lang][key]
+	if !ok {
+		if msg, ok = catalogs["en"][key]; !ok {
+			msg = key
+		}
+	}
+	if len(args) > 0 {
+		return fmt.Sprintf(msg, args...)
+	}
+	return msg
+}
@@ -0,0 +1,34 @@
+package main
+
+import "testing"
+
+func TestDiscussionsTranslated(t *testing.T) {
+	t.Setenv("LANG", "nl_NL.UTF-8")
+	if got, want := (MR{UnresolvedCount: 2}).Discussions(), "💬 2 onopgelost"; got != want {
+		t.Errorf("Discussions() = %q, want %q", got, want)
+	}
+	t.Setenv("LANG", "C")
+	if got, want := (MR{UnresolvedCount: 2}).Discussions(), "💬 2 unresolved"; got != want {
+		t.Errorf("Discussions() = %q, want %q", got, want)
+	}
+}
+
+func TestCatalogsHaveEnglish(t *testing.T) {
+	for lang, msgs := range catalogs {
+		for key := range msgs {
+			if _, ok := catalogs["en"][key]; !ok {
+				t.Errorf("%s: %q has no English text", lang, key)
+			}
+		}
+	}
+}
+
+func TestCatalogsComplete(t *testing.T) {
+	for lang, msgs := range catalogs {
+		for key := range catalogs["en"] {
+			if _, ok := msgs[key]; !ok {
+				t.Errorf("%s: %q is missing", lang, key)
+			}
+		}
+	}
+}
@@ -1,89 +1,442 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"embed"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
+	"hash/fnv"
 	"html/template"
-	"log"
+	"io"
+	"io/fs"
+	"log/slog"
 	"net/http"
+	"net/url"
 	"os"
+	"slices"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/joho/godotenv"
+	"golang.org/x/sync/errgroup"
 )
 
 type MR struct {
-	ID        int       `json:"id"`
-	IID       int       `json:"iid"`
-	ProjectID int       `json:"project_id"`
-	Title     string    `json:"title"`
-	WebURL    string    `json:"web_url"`
-	UpdatedAt time.Time `json:"updated_at"`
-	Author    struct {
-		Name string `json:"name"`
-	} `json:"author"`
+	Host       string    `json:"-"`
+	Provider   string    `json:"-"` // "github" for pull requests, else GitLab
+	ID         int       `json:"id"`
+	IID        int       `json:"iid"`
+	ProjectID  int       `json:"project_id"`
+	Title      string    `json:"title"`
+	WebURL     string    `json:"web_url"`
+	CreatedAt  Timestamp `json:"created_at"`
+	UpdatedAt  Timestamp `json:"updated_at"`
+	Author     Author    `json:"author"`
+	Reviewers  []Author  `json:"reviewers"`
 	References struct {
 		Full string `json:"full"`
 	} `json:"references"`
-	HeadPipeline *struct {
-		ID     int    `json:"id"`
-		Status string `json:"status"`
-		WebURL string `json:"web_url"`
-	} `json:"head_pipeline"`
+	HeadPipeline        *Pipeline  `json:"head_pipeline"`
+	PipelineUnknown     bool       `json:"-"` // the pipeline lookup failed
+	PipelineKnown       bool       `json:"-"` // HeadPipeline is final, even if nil (GraphQL)
+	Milestone           *Milestone `json:"milestone"`
+	MergeStatus         string     `json:"merge_status"`
+	DetailedMergeStatus string     `json:"detailed_merge_status"`
+	Labels              []string   `json:"labels"`
+	HasConflicts        bool       `json:"has_conflicts"`
+
+	UserNotesCount              int   `json:"user_notes_count"`
+	BlockingDiscussionsResolved *bool `json:"blocking_discussions_resolved"`
+	UnresolvedCount             int   `json:"-"` // only with FETCH_DISCUSSIONS or GITLAB_GRAPHQL
+
+	ProjectColor template.CSS `json:"-"`
+
+	// My reviewer state, only set on MRs where I'm a reviewer.
+	ReviewState string `json:"-"`
+	// A review request from someone in TEAMMATE_USERNAMES.
+	FromTeam bool `json:"-"`
+}
+
+// MergeState folds detailed_merge_status (or the older merge_status) into
+// mergeable, checking or blocked. Empty when GitLab returned neither.
+func (m MR) MergeState() string {
+	switch m.DetailedMergeStatus {
+	case "":
+	case "mergeable":
+		return "mergeable"
+	case "checking", "unchecked", "preparing", "approvals_syncing":
+		return "checking"
+	default:
+		return "blocked"
+	}
+	switch m.MergeStatus {
+	case "can_be_merged":
+		return "mergeable"
+	case "checking", "unchecked":
+		return "checking"
+	case "cannot_be_merged", "cannot_be_merged_recheck":
+		return "blocked"
+	}
+	return ""
+}
+
+// ReviewerNames is "unassigned" when nobody has been asked to review.
+func (m MR) ReviewerNames() string {
+	if len(m.Reviewers) == 0 {
+		return "unassigned"
+	}
+	names := make([]string, len(m.Reviewers))
+	for i, r := range m.Reviewers {
+		names[i] = r.Name
+	}
+	return strings.Join(names, ", ")
+}
+
+type Author struct {
+	Username  string `json:"username"`
+	Name      string `json:"name"`
+	AvatarURL string `json:"avatar_url"`
+}
+
+// Initials stands in for the avatar when GitLab has none.
+func (a Author) Initials() string {
+	var out []rune
+	for _, f := range strings.Fields(a.Name) {
+		out = append(out, []rune(strings.ToUpper(f))[0])
+		if len(out) == 2 {
+			break
+		}
+	}
+	return string(out)
 }
 
 type Todo struct {
+	Host       string `json:"-"`
 	ID         int    `json:"id"`
 	ActionName string `json:"action_name"`
 	TargetType string `json:"target_type"`
 	Target     struct {
+		IID    int    `json:"iid"`
 		Title  string `json:"title"`
 		WebURL string `json:"web_url"`
+		State  string `json:"state"`
 	} `json:"target"`
 	Project struct {
+		ID   int    `json:"id"`
 		Name string `json:"name"`
 	} `json:"project"`
-	CreatedAt time.Time `json:"created_at"`
+	Author    Author    `json:"author"`
+	CreatedAt Timestamp `json:"created_at"`
+}
+
+type Issue struct {
+	Host       string     `json:"-"`
+	ID         int        `json:"id"`
+	IID        int        `json:"iid"`
+	ProjectID  int        `json:"project_id"`
+	Title      string     `json:"title"`
+	WebURL     string     `json:"web_url"`
+	UpdatedAt  Timestamp  `json:"updated_at"`
+	DueDate    string     `json:"due_date"` // YYYY-MM-DD or empty
+	Milestone  *Milestone `json:"milestone"`
+	Author     Author     `json:"author"`
+	References struct {
+		Full string `json:"full"`
+	} `json:"references"`
+	ProjectColor template.CSS `json:"-"`
+}
+
+type Milestone struct {
+	ID      int    `json:"id"`
+	Title   string `json:"title"`
+	DueDate string `json:"due_date"` // YYYY-MM-DD; not in the GraphQL query
+	WebURL  string `json:"web_url"`
 }
 
 type Pipeline struct {
-	ID     int    `json:"id"`
-	Status string `json:"status"`
-	WebURL string `json:"web_url"`
+	ID         int       `json:"id"`
+	Status     string    `json:"status"`
+	WebURL     string    `json:"web_url"`
+	CreatedAt  Timestamp `json:"created_at"`
+	UpdatedAt  Timestamp `json:"updated_at"`
+	StartedAt  Timestamp `json:"started_at"`  // zero until a job starts
+	FinishedAt Timestamp `json:"finished_at"` // not in the MR pipelines list
+}
+
+var httpClient = &http.Client{Timeout: 10 * time.Second}
+
+// newHTTPClient honours HTTP_PROXY/HTTPS_PROXY/NO_PROXY and, with
+// GITLAB_INSECURE=1, skips TLS verification for self-signed instances.
+func newHTTPClient() *http.Client {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.Proxy = http.ProxyFromEnvironment
+	if envBool("GITLAB_INSECURE") {
+		slog.Warn("GITLAB_INSECURE is set, TLS certificates of GitLab hosts are NOT verified")
+		t.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+	entries, err := envInt("ETAG_CACHE_ENTRIES", 500)
+	if err != nil {
+		fatal("config", "err", err)
+	}
+	return &http.Client{Timeout: 10 * time.Second, Transport: &metricsTransport{newETagTransport(newRetryTransport(t), entries), gitlabHostSet()}}
+}
+
+// gitlabHostSet is the hosts in GITLAB_BASE, to tell GitLab calls from
+// others.
+func gitlabHostSet() map[string]bool {
+	hosts := map[string]bool{}
+	for _, b := range splitUsers(os.Getenv("GITLAB_BASE")) {
+		if u, err := url.Parse(b); err == nil {
+			hosts[u.Host] = true
+		}
+	}
+	return hosts
+}
+
+// APIError is returned for any non-2xx GitLab response.
+type APIError struct {
+	Method     string
+	StatusCode int
+	URL        string
+	Body       string // first few hundred bytes of the response
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%s %s -> %d %s", e.Method, redactURL(e.URL), e.StatusCode, http.StatusText(e.StatusCode))
+}
+
+func newAPIError(url string, resp *http.Response) *APIError {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	return &APIError{Method: resp.Request.Method, StatusCode: resp.StatusCode, URL: url, Body: string(body)}
+}
+
+// Strip token-bearing query params before a URL ends up in a log line.
+func redactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return raw
+	}
+	q := u.Query()
+	for _, k := range []string{"private_token", "access_token", "job_token"} {
+		if q.Has(k) {
+			q.Set(k, "REDACTED")
+		}
+	}
+	u.RawQuery = q.Encode()
+	return u.String()
 }
 
-func apiGet(url, token string, v any) error {
-	req, _ := http.NewRequest("GET", url, nil)
+// errorHint turns an apiGet error into something a human can act on.
+func errorHint(err error) string {
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		hints := make([]string, 0, len(joined.Unwrap()))
+		for _, e := range joined.Unwrap() {
+			hints = append(hints, errorHint(e))
+		}
+		return strings.Join(hints, "\n")
+	}
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return err.Error()
+	}
+	switch {
+	case apiErr.StatusCode == http.StatusUnauthorized || apiErr.StatusCode == http.StatusForbidden:
+		return "GitLab rejected the request, check your token: " + apiErr.Error()
+	case apiErr.StatusCode == http.StatusTooManyRequests:
+		return "GitLab is rate limiting us, slow down: " + apiErr.Error()
+	default:
+		return apiErr.Error()
+	}
+}
+
+// sectionError tags a failed fetch with the list it was for, so the page
+// can say what is missing.
+type sectionError struct {
+	Part string // message key, e.g. "part.reviewer"
+	Host string
+	Err  error
+}
+
+func (e *sectionError) Error() string { return e.Host + " " + e.Part + ": " + e.Err.Error() }
+func (e *sectionError) Unwrap() error { return e.Err }
+
+// tagError wraps err as part's failure on host. Errors that were already
+// tagged, more precisely, pass through.
+func tagError(part, host string, err error) error {
+	var tagged *sectionError
+	if err == nil || errors.As(err, &tagged) {
+		return err
+	}
+	return &sectionError{part, host, err}
+}
+
+type failure struct {
+	Part    string // message key
+	Host    string
+	Message string
+}
+
+// failures flattens a collect error into one line per failed list.
+func failures(err error) []failure {
+	if err == nil {
+		return nil
+	}
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		var out []failure
+		for _, e := range joined.Unwrap() {
+			out = append(out, failures(e)...)
+		}
+		return out
+	}
+	var tagged *sectionError
+	if errors.As(err, &tagged) {
+		return []failure{{tagged.Part, tagged.Host, errorHint(tagged.Err)}}
+	}
+	return []failure{{"part.other", "", errorHint(err)}}
+}
+
+// OAuth tokens are passed around as "Bearer <token>", personal access
+// tokens as is.
+func setAuth(req *http.Request, token string) {
+	if strings.HasPrefix(token, "Bearer ") {
+		req.Header.Set("Authorization", token)
+		return
+	}
 	req.Header.Set("PRIVATE-TOKEN", token)
+}
+
+func apiGet(ctx context.Context, url, token string, v any) error {
+	req, _ := http.NewRequestWithContext(ctx, "GET", url, nil)
+	setAuth(req, token)
 	req.Header.Set("Accept", "application/json")
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Do(req)
+	resp, err := httpClient.Do(req)
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode >= 300 {
-		return fmt.Errorf("GET %s -> %s", url, resp.Status)
+		return newAPIError(url, resp)
 	}
 	return json.NewDecoder(resp.Body).Decode(v)
 }
 
+// GitLab answers 304 when the todo was already done; that counts as success.
+func markTodoDone(ctx context.Context, base, token string, id int) error {
+	url := fmt.Sprintf("%s/api/v4/todos/%d/mark_as_done", base, id)
+	req, _ := http.NewRequestWithContext(ctx, "POST", url, nil)
+	setAuth(req, token)
+	req.Header.Set("Accept", "application/json")
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 && resp.StatusCode != http.StatusNotModified {
+		return newAPIError(url, resp)
+	}
+	return nil
+}
+
+// Key identifies an MR across hosts.
+func (m MR) Key() string {
+	if m.Provider == "github" {
+		return m.Host + ":" + m.References.Full
+	}
+	return fmt.Sprintf("%s:%d:%d", m.Host, m.ProjectID, m.IID)
+}
+
+// uniqMRs drops duplicate MRs, merging each duplicate into the copy kept.
 func uniqMRs(in []MR) []MR {
-	seen := map[string]bool{}
+	seen := map[string]int{}
 	out := make([]MR, 0, len(in))
 	for _, m := range in {
-		key := fmt.Sprintf("%d:%d", m.ProjectID, m.IID)
-		if !seen[key] {
-			seen[key] = true
-			out = append(out, m)
+		key := m.Key()
+		if i, ok := seen[key]; ok {
+			out[i] = mergeMR(out[i], m)
+			continue
 		}
+		seen[key] = len(out)
+		out = append(out, m)
 	}
-	sort.Slice(out, func(i, j int) bool { return out[i].UpdatedAt.After(out[j].UpdatedAt) })
 	return out
 }
 
+// mergeMR combines two copies of one MR: the most recently updated copy
+// wins and any pipeline or review state it lacks is taken from the other.
+func mergeMR(a, b MR) MR {
+	if b.UpdatedAt.After(a.UpdatedAt.Time) {
+		a, b = b, a
+	}
+	if a.HeadPipeline == nil {
+		a.HeadPipeline = b.HeadPipeline
+		a.PipelineUnknown = b.PipelineUnknown
+	}
+	if a.ReviewState == "" {
+		a.ReviewState = b.ReviewState
+	}
+	a.FromTeam = a.FromTeam || b.FromTeam
+	return a
+}
+
+// Most recently updated first.
+func sortMRsByUpdated(mrs []MR) []MR {
+	sort.SliceStable(mrs, func(i, j int) bool { return mrs[i].UpdatedAt.After(mrs[j].UpdatedAt.Time) })
+	return mrs
+}
+
+// sortTeamMRs orders the team sidebar by TEAM_SORT: "updated" (most
+// recent first), "author" or "project". Ties fall back to most recently
+// updated.
+func sortTeamMRs(mrs []MR, by string) []MR {
+	key := func(m MR) string { return "" }
+	switch by {
+	case "author":
+		key = func(m MR) string { return strings.ToLower(m.Author.Name) }
+	case "project":
+		key = func(m MR) string { return strings.ToLower(projectPath(m.References.Full)) }
+	}
+	sort.SliceStable(mrs, func(i, j int) bool {
+		if ki, kj := key(mrs[i]), key(mrs[j]); ki != kj {
+			return ki < kj
+		}
+		return mrs[i].UpdatedAt.After(mrs[j].UpdatedAt.Time)
+	})
+	return mrs
+}
+
+var teamSorts = []string{"updated", "author", "project"}
+
+// Review queue order: failing pipelines, then conflicts, then least
+// recently updated.
+func sortMRsByPriority(mrs []MR) []MR {
+	rank := func(m MR) int {
+		switch {
+		case m.HeadPipeline != nil && m.HeadPipeline.Status == "failed":
+			return 0
+		case m.HasConflicts:
+			return 1
+		default:
+			return 2
+		}
+	}
+	sort.SliceStable(mrs, func(i, j int) bool {
+		ri, rj := rank(mrs[i]), rank(mrs[j])
+		if ri != rj {
+			return ri < rj
+		}
+		return mrs[i].UpdatedAt.Before(mrs[j].UpdatedAt.Time)
+	})
+	return mrs
+}
+
 func splitUsers(s string) []string {
 	if s == "" {
 		return nil
@@ -99,279 +452,1029 @@ func splitUsers(s string) []string {
 	return out
 }
 
-// Attach latest pipeline if head_pipeline missing.
-func attachPipelines(base, token string, mrs []MR) []MR {
-	for i := range mrs {
-		if mrs[i].HeadPipeline != nil {
-			continue
+type gitlabHost struct {
+	Base  string
+	Token string
+	Name  string
+	User  string // the username on this instance
+}
+
+type config struct {
+	Hosts        []gitlabHost
+	User         string
+	TeamUsers    []string
+	Teams        []team // TEAMS; ?team= picks which one fills TeamUsers
+	Team         string // selected team name, if any
+	PersonalTeam bool   // TeamUsers is the OAuth user's own list
+	TeamProjects []string
+	Labels       []string
+	Milestone    string   // MR_MILESTONE, or ?milestone= on the page
+	Sections     []string // in display order; "team" always lives in the sidebar
+	TodoActions  []string
+	MaxAgeDays   int    // 0 shows MRs of any age
+	TeamSort     string // one of teamSorts
+
+	PipelineIgnore []string // statuses shown as no pipeline, e.g. skipped
+	GraphQL        bool     // GITLAB_GRAPHQL: MR lists over GraphQL
+
+	IncludeProjectIDs map[int]bool // empty allows every project
+	ExcludeProjectIDs map[int]bool
+
+	OAuth    *oauthConfig   // nil unless the OAuth login is configured
+	GitHub   *githubConfig  // nil without GITHUB_TOKEN
+	Location *time.Location // DISPLAY_TZ, for calendar grouping
+
+	RefreshSeconds     int // full page reload; 0 disables it
+	TimeRefreshSeconds int // relative timestamps
+
+	Branding branding
+}
+
+var defaultSections = []string{"mine", "issues", "todos", "team"}
+
+// knownSections are the SECTIONS values; "approved" is opt-in.
+var knownSections = append(slices.Clone(defaultSections), "approved")
+
+func (c config) enabled(section string) bool {
+	return slices.Contains(c.Sections, section)
+}
+
+// GITLAB_BASE and GITLAB_TOKEN are comma-separated and paired by position.
+// Tokens may be sealed with TOKEN_KEY; see secrets.go.
+func loadConfig() (config, error) {
+	bases := splitUsers(os.Getenv("GITLAB_BASE")) // e.g., https://gitlab.com
+	tokens := splitUsers(os.Getenv("GITLAB_TOKEN"))
+	users := splitUsers(os.Getenv("GITLAB_USERNAME")) // one for all hosts, or one per host
+	for i, t := range tokens {
+		var err error
+		if tokens[i], err = openSecret(t); err != nil {
+			return config{}, fmt.Errorf("GITLAB_TOKEN: %w", err)
+		}
+	}
+	cfg := config{
+		TeamUsers:    splitUsers(os.Getenv("TEAMMATE_USERNAMES")),
+		TeamProjects: splitUsers(os.Getenv("TEAM_PROJECTS")),
+		Labels:       splitUsers(os.Getenv("MR_LABELS")),
+		Milestone:    strings.TrimSpace(os.Getenv("MR_MILESTONE")),
+		TodoActions:  splitUsers(os.Getenv("TODO_ACTIONS")),
+
+		PipelineIgnore: splitUsers(strings.ToLower(os.Getenv("PIPELINE_IGNORE"))),
+		GraphQL:        envBool("GITLAB_GRAPHQL"),
+	}
+	if len(users) > 0 {
+		cfg.User = users[0] // shown in the header, and keys snoozes
+	}
+	var err error
+	if cfg.OAuth, err = loadOAuthConfig(); err != nil {
+		return cfg, err
+	}
+	if cfg.GitHub, err = loadGitHubConfig(); err != nil {
+		return cfg, err
+	}
+	if len(bases) == 0 || (cfg.OAuth == nil && (len(tokens) == 0 || cfg.User == "")) {
+		return cfg, fmt.Errorf("Set env vars: GITLAB_BASE, GITLAB_TOKEN, GITLAB_USERNAME")
+	}
+	if cfg.Sections = splitUsers(os.Getenv("SECTIONS")); cfg.Sections == nil {
+		cfg.Sections = defaultSections
+	}
+	for _, sec := range cfg.Sections {
+		if !slices.Contains(knownSections, sec) {
+			return cfg, fmt.Errorf("SECTIONS: unknown section %q, expected some of %s", sec, strings.Join(knownSections, ","))
+		}
+	}
+	if cfg.RefreshSeconds, err = envInt("REFRESH_SECONDS", 60); err != nil {
+		return cfg, err
+	}
+	if cfg.TimeRefreshSeconds, err = envInt("TIME_REFRESH_SECONDS", 30); err != nil {
+		return cfg, err
+	}
+	if cfg.Teams, err = parseTeams(os.Getenv("TEAMS")); err != nil {
+		return cfg, fmt.Errorf("TEAMS: %w", err)
+	}
+	if len(cfg.Teams) > 0 && len(cfg.TeamUsers) == 0 {
+		cfg.Team, cfg.TeamUsers = cfg.Teams[0].Name, cfg.Teams[0].Users
+	}
+	if cfg.MaxAgeDays, err = envInt("MAX_AGE_DAYS", 0); err != nil {
+		return cfg, err
+	}
+	if cfg.IncludeProjectIDs, err = parseIDSet(os.Getenv("INCLUDE_PROJECT_IDS")); err != nil {
+		return cfg, fmt.Errorf("INCLUDE_PROJECT_IDS: %w", err)
+	}
+	if cfg.ExcludeProjectIDs, err = parseIDSet(os.Getenv("EXCLUDE_PROJECT_IDS")); err != nil {
+		return cfg, fmt.Errorf("EXCLUDE_PROJECT_IDS: %w", err)
+	}
+	if cfg.TeamSort = strings.ToLower(strings.TrimSpace(os.Getenv("TEAM_SORT"))); cfg.TeamSort == "" {
+		cfg.TeamSort = "updated"
+	}
+	if !slices.Contains(teamSorts, cfg.TeamSort) {
+		return cfg, fmt.Errorf("TEAM_SORT: unknown order %q, expected one of %s", cfg.TeamSort, strings.Join(teamSorts, ","))
+	}
+	cfg.Location = time.Local
+	if cfg.Branding, err = loadBranding(); err != nil {
+		return cfg, err
+	}
+	if tz := os.Getenv("DISPLAY_TZ"); tz != "" {
+		if cfg.Location, err = time.LoadLocation(tz); err != nil {
+			return cfg, fmt.Errorf("DISPLAY_TZ: %w", err)
+		}
+	}
+	if cfg.OAuth != nil {
+		// Every visitor brings their own token for the first host; a
+		// GITLAB_TOKEN is still used by -cli and -check.
+		bases, tokens = bases[:1], append(tokens, "")[:1]
+	}
+	if len(bases) != len(tokens) {
+		return cfg, fmt.Errorf("GITLAB_BASE has %d entries but GITLAB_TOKEN has %d", len(bases), len(tokens))
+	}
+	if len(users) > 1 && len(users) != len(bases) {
+		return cfg, fmt.Errorf("GITLAB_BASE has %d entries but GITLAB_USERNAME has %d", len(bases), len(users))
+	}
+	for i, b := range bases {
+		b = strings.TrimRight(b, "/")
+		name := b
+		if u, err := url.Parse(b); err == nil && u.Host != "" {
+			name = u.Host
+		}
+		user := cfg.User
+		if len(users) > 1 {
+			user = users[i]
+		}
+		cfg.Hosts = append(cfg.Hosts, gitlabHost{Base: b, Token: tokens[i], Name: name, User: user})
+	}
+	return cfg, nil
+}
+
+// ageCutoff is the oldest UpdatedAt still shown, or zero without
+// MAX_AGE_DAYS.
+func (c config) ageCutoff() time.Time {
+	if c.MaxAgeDays <= 0 {
+		return time.Time{}
+	}
+	return time.Now().AddDate(0, 0, -c.MaxAgeDays)
+}
+
+// Extra query params appended to every merge_requests call.
+func (c config) mrFilter() string {
+	var f string
+	if len(c.Labels) > 0 {
+		f += "&labels=" + url.QueryEscape(strings.Join(c.Labels, ","))
+	}
+	if c.Milestone != "" {
+		f += "&milestone=" + url.QueryEscape(milestoneParam(c.Milestone))
+	}
+	return f
+}
+
+// milestoneParam spells GitLab's special milestone values (None, Any,
+// Upcoming, Started) the way the API expects; other titles pass as is.
+func milestoneParam(m string) string {
+	for _, special := range []string{"None", "Any", "Upcoming", "Started"} {
+		if strings.EqualFold(m, special) {
+			return special
+		}
+	}
+	return m
+}
+
+func (c config) hostNames() string {
+	names := make([]string, len(c.Hosts))
+	for i, h := range c.Hosts {
+		names[i] = h.Name
+	}
+	if c.GitHub != nil {
+		names = append(names, c.GitHub.Name)
+	}
+	return strings.Join(names, ", ")
+}
+
+func envInt(key string, def int) (int, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("%s must be a non-negative integer, got %q", key, v)
+	}
+	return n, nil
+}
+
+func envBool(key string) bool {
+	b, _ := strconv.ParseBool(os.Getenv(key))
+	return b
+}
+
+// Drop todos pointing at an MR that is already on the page.
+func filterRedundantTodos(todos []Todo, mrs []MR) []Todo {
+	shown := make(map[string]bool, len(mrs))
+	for _, m := range mrs {
+		shown[m.WebURL] = true
+	}
+	out := make([]Todo, 0, len(todos))
+	for _, t := range todos {
+		if !shown[t.Target.WebURL] {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+// projectPath returns "group/project" from a full reference such as
+// "group/project!123" (MRs) or "group/project#45" (issues), or from a
+// project's web URL. It is empty for a URL that names no project.
+func projectPath(ref string) string {
+	if u, err := url.Parse(ref); err == nil && u.Host != "" {
+		p := u.Path
+		if before, _, ok := strings.Cut(p, "/-/"); ok {
+			p = before
+		}
+		return strings.Trim(p, "/")
+	}
+	if i := strings.LastIndexAny(ref, "!#"); i >= 0 {
+		ref = ref[:i]
+	}
+	return strings.Trim(ref, "/")
+}
+
+// projectColor hashes a project path to a hue, so each project keeps the
+// same accent colour across reloads. Typed as CSS so the template can put
+// it in a style attribute.
+func projectColor(path string) template.CSS {
+	h := fnv.New32a()
+	h.Write([]byte(path))
+	return template.CSS(fmt.Sprintf("hsl(%d, 65%%, 50%%)", h.Sum32()%360))
+}
+
+// parseIDSet parses a comma-separated list of project IDs.
+func parseIDSet(s string) (map[int]bool, error) {
+	set := map[int]bool{}
+	for _, f := range splitUsers(s) {
+		id, err := strconv.Atoi(f)
+		if err != nil || id <= 0 {
+			return nil, fmt.Errorf("invalid project ID %q", f)
 		}
-		var pipes []Pipeline
-		u := fmt.Sprintf("%s/api/v4/projects/%d/merge_requests/%d/pipelines?per_page=1", base, mrs[i].ProjectID, mrs[i].IID)
-		if err := apiGet(u, token, &pipes); err != nil || len(pipes) == 0 {
+		set[id] = true
+	}
+	return set, nil
+}
+
+// filterByProjectID drops MRs from excluded projects and, when include is
+// non-empty, from projects not in it. Exclusion wins.
+func filterByProjectID(mrs []MR, include, exclude map[int]bool) []MR {
+	if len(include) == 0 && len(exclude) == 0 {
+		return mrs
+	}
+	out := make([]MR, 0, len(mrs))
+	for _, m := range mrs {
+		if exclude[m.ProjectID] || (len(include) > 0 && !include[m.ProjectID]) {
 			continue
 		}
-		p := pipes[0]
-		mrs[i].HeadPipeline = &struct {
-			ID     int    `json:"id"`
-			Status string `json:"status"`
-			WebURL string `json:"web_url"`
-		}{ID: p.ID, Status: p.Status, WebURL: p.WebURL}
+		out = append(out, m)
 	}
-	return mrs
+	return out
+}
+
+// GitLab usernames are case-insensitive.
+func containsUser(users []string, username string) bool {
+	return slices.ContainsFunc(users, func(u string) bool { return strings.EqualFold(u, username) })
+}
+
+// Keep MRs whose project path is in projects, given as paths or web URLs;
+// an empty list keeps all.
+func filterByProject(mrs []MR, projects []string) []MR {
+	if len(projects) == 0 {
+		return mrs
+	}
+	out := make([]MR, 0, len(mrs))
+	for _, m := range mrs {
+		p := projectPath(m.References.Full)
+		for _, want := range projects {
+			if strings.EqualFold(p, projectPath(want)) {
+				out = append(out, m)
+				break
+			}
+		}
+	}
+	return out
+}
+
+// filterByAge keeps MRs updated at or after cutoff; a zero cutoff keeps
+// all.
+func filterByAge(mrs []MR, cutoff time.Time) []MR {
+	if cutoff.IsZero() {
+		return mrs
+	}
+	out := make([]MR, 0, len(mrs))
+	for _, m := range mrs {
+		if !m.UpdatedAt.Before(cutoff) {
+			out = append(out, m)
+		}
+	}
+	return out
 }
 
-func collectTeammateMRs(base, token string, users []string) []MR {
+// userMRs lists the open MRs where user is the "assignee", "reviewer" or
+// "author". Reviewer MRs carry user's review state.
+func userMRs(ctx context.Context, cfg config, h gitlabHost, role, user string) ([]MR, error) {
+	if cfg.GraphQL {
+		return graphqlUserMRs(ctx, cfg, h, role, user)
+	}
+	filter := cfg.mrFilter() + pipelineInclude(ctx, h)
+	mrs, err := fetch[MR](ctx, httpClient, fmt.Sprintf("%s/api/v4/merge_requests?scope=all&state=opened&%s_username=%s&per_page=100%s", h.Base, role, url.QueryEscape(user), filter), h.Token)
+	if role == "reviewer" {
+		mrs = attachReviewStates(ctx, h.Base, h.Token, user, mrs)
+	}
+	return mrs, err
+}
+
+// collectTeammateMRs fetches the open MRs each teammate authored or is
+// assigned to, a few users at a time. Both APIs only filter on a single
+// author/assignee per call, so this stays two requests per user; each
+// user's results are deduplicated before they are merged.
+func collectTeammateMRs(ctx context.Context, cfg config, h gitlabHost, users []string) ([]MR, error) {
 	if len(users) == 0 {
-		return nil
+		return nil, nil
+	}
+	results := make([][]MR, len(users))
+	errs := make([]error, len(users))
+	sem := make(chan struct{}, fetchWorkers)
+	var wg sync.WaitGroup
+	for i, u := range users {
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, u string) {
+			defer func() { <-sem; wg.Done() }()
+			authored, errA := userMRs(ctx, cfg, h, "author", u)
+			assigned, errB := userMRs(ctx, cfg, h, "assignee", u)
+			results[i] = uniqMRs(append(authored, assigned...))
+			errs[i] = errors.Join(errA, errB)
+		}(i, u)
 	}
+	wg.Wait()
 	buf := make([]MR, 0, 64)
-	for _, u := range users {
-		var authored []MR
-		var assigned []MR
-		_ = apiGet(fmt.Sprintf("%s/api/v4/merge_requests?scope=all&state=opened&author_username=%s&per_page=100&include=head_pipeline", base, u), token, &authored)
-		_ = apiGet(fmt.Sprintf("%s/api/v4/merge_requests?scope=all&state=opened&assignee_username=%s&per_page=100&include=head_pipeline", base, u), token, &assigned)
-		buf = append(buf, authored...)
-		buf = append(buf, assigned...)
-	}
-	return uniqMRs(buf)
-}
-
-var page = template.Must(template.New("p").Parse(`
-<!doctype html>
-<meta charset="utf-8">
-<meta name="viewport" content="width=device-width, initial-scale=1">
-<meta name="color-scheme" content="light dark">
-<title>GitLab dashboard – {{.User}}</title>
-<style>
-:root{
-  /* light */
-  --bg:#f6f7fb;
-  --panel:#ffffff;
-  --panel-2:#f2f4f8;
-  --text:#0b1220;
-  --muted:#566173;
-  --brand:#0b63ff;
-  --border:#dbe1ea;
-}
-@media (prefers-color-scheme: dark){
-  :root{
-    --bg:#0b1020;
-    --panel:#111731;
-    --panel-2:#0e142a;
-    --text:#e8ecf1;
-    --muted:#9aa6b2;
-    --brand:#6aa3ff;
-    --border:#223056;
-  }
-}
-*{box-sizing:border-box}
-body{
-  margin:0;padding:24px;min-height:100vh;
-  font:15px/1.5 system-ui, Segoe UI, Roboto, Helvetica, Arial, "Apple Color Emoji","Segoe UI Emoji";
-  color:var(--text);
-  background:var(--bg);
-}
-@media (prefers-color-scheme: dark){
-  body{background:radial-gradient(1200px 800px at 100% -20%, #1a2447 0%, rgba(26,36,71,0) 60%), var(--bg);}
-}
-.container{max-width:1100px;margin:0 auto}
-.header{display:flex;align-items:center;justify-content:space-between;gap:16px;margin-bottom:18px}
-.brand{display:flex;align-items:center;gap:12px}
-.brand .logo{width:34px;height:34px;border-radius:8px;background:linear-gradient(135deg, var(--brand), #b38cff)}
-.brand h1{font-size:20px;margin:0}
-.topline{color:var(--muted);font-size:12px;margin-bottom:18px}
-.section{margin-top:26px}
-.section h2{font-size:16px;color:var(--muted);margin:0 0 10px 0}
-.grid{display:grid;grid-template-columns:repeat(auto-fill, minmax(320px,1fr));gap:12px}
-.card{
-  background:linear-gradient(180deg, var(--panel), var(--panel-2));
-  border:1px solid var(--border);border-radius:14px;padding:14px;
-  transition:transform .08s ease, box-shadow .2s ease, border-color .2s ease
-}
-@media (prefers-color-scheme: dark){
-  .card{box-shadow:0 6px 18px rgba(0,0,0,.25)}
-  .card:hover{transform:translateY(-2px);box-shadow:0 10px 24px rgba(0,0,0,.35);border-color:#2c3e70}
-}
-.card .title{font-weight:600;margin-bottom:6px}
-.card .title a{color:var(--text);text-decoration:none}
-.card .title a:hover{color:var(--brand)}
-.meta{display:flex;flex-wrap:wrap;gap:8px;align-items:center;color:var(--muted);font-size:12px}
-.badge{display:inline-block;padding:2px 8px;border-radius:999px;border:1px solid var(--border);background:var(--panel-2);color:var(--text);font-size:11px}
-.small{color:var(--muted);font-size:12px}
-.empty{color:var(--muted);font-size:13px;padding:10px;border:1px dashed var(--border);border-radius:10px;background:var(--panel-2)}
-hr.sep{border:none;border-top:1px solid var(--border);margin:10px 0}
-a{color:var(--brand);text-decoration:none}
-a:hover{text-decoration:underline}
-footer{margin-top:28px;color:var(--muted);font-size:12px}
-.layout{display:grid;grid-template-columns:280px 1fr;gap:16px}
-.sidebar{background:linear-gradient(180deg, var(--panel), var(--panel-2));border:1px solid var(--border);border-radius:14px;padding:14px;height:fit-content;position:sticky;top:16px}
-.sidebar h2{font-size:15px;margin:0 0 8px 0;color:var(--muted)}
-.list{list-style:none;margin:0;padding:0;display:flex;flex-direction:column;gap:8px}
-.list li a{color:var(--text);text-decoration:none}
-.list li a:hover{color:var(--brand)}
-.content{min-width:0}
-@media (max-width: 860px){.layout{grid-template-columns:1fr}.sidebar{position:static}}
-/* pipeline dots */
-.pipe{display:inline-flex;align-items:center;gap:6px}
-.dot{display:inline-block;width:10px;height:10px;border-radius:50%;background:#3b82f6;box-shadow:0 0 0 1px var(--border)}
-.dot[data-status="success"]{background:#22c55e}
-.dot[data-status="failed"]{background:#ef4444}
-</style>
-<div class="container">
-  <div class="header">
-    <div class="brand">
-      <div class="logo"></div>
-      <h1>GitLab dashboard</h1>
-    </div>
-    <div class="small">Ingelogd als <strong>{{.User}}</strong></div>
-  </div>
-  <div class="topline">Host: {{.Base}} • Auto-refresh elke 60s</div>
-
-  <div class="layout">
-    <aside class="sidebar">
-      <h2>Team MR’s</h2>
-      {{if .TeamMRs}}
-        <ul class="list">
-        {{range .TeamMRs}}
-          <li>
-            <a target="_blank" rel="noopener noreferrer" href="{{.WebURL}}">{{.Title}}</a>
-            <div class="small">{{.References.Full}} • {{.Author.Name}}</div>
-            {{if .HeadPipeline}}
-              <a class="pipe" target="_blank" rel="noopener noreferrer" href="{{.HeadPipeline.WebURL}}" title="pipeline: {{.HeadPipeline.Status}}">
-                <span class="dot" data-status="{{.HeadPipeline.Status}}"></span>
-              </a>
-            {{end}}
-          </li>
-        {{end}}
-        </ul>
-      {{else}}
-        <div class="empty">Geen team-MR’s.</div>
-      {{end}}
-      <hr class="sep"/>
-      <div class="small">Bron: auteurs of assignees uit <code>TEAMMATE_USERNAMES</code></div>
-    </aside>
-
-    <main class="content">
-      <div class="section">
-        <h2>Open Merge Requests <span class="small">(assignee + reviewer)</span></h2>
-        {{if .MRs}}
-          <div class="grid">
-          {{range .MRs}}
-            <div class="card">
-              <div class="title"><a target="_blank" rel="noopener noreferrer" href="{{.WebURL}}">{{.Title}}</a></div>
-              <div class="meta">
-                <span class="badge">{{.References.Full}}</span>
-                <span>door {{.Author.Name}}</span>
-                {{if .HeadPipeline}}
-                  <a class="pipe" target="_blank" rel="noopener noreferrer" href="{{.HeadPipeline.WebURL}}" title="pipeline: {{.HeadPipeline.Status}}">
-                    <span class="dot" data-status="{{.HeadPipeline.Status}}"></span>
-                  </a>
-                {{end}}
-                <span>•</span>
-                <span>laatst geüpdatet</span>
-                <time class="timeago" datetime="{{.UpdatedAt.Format "2006-01-02T15:04:05Z07:00"}}"></time>
-              </div>
-            </div>
-          {{end}}
-          </div>
-        {{else}}
-          <div class="empty">Geen open MR’s.</div>
-        {{end}}
-      </div>
-
-      <div class="section">
-        <h2>Todos</h2>
-        {{if .Todos}}
-          <div class="grid">
-          {{range .Todos}}
-            <div class="card">
-              <div class="title"><a target="_blank" rel="noopener noreferrer" href="{{.Target.WebURL}}">{{.Target.Title}}</a></div>
-              <div class="meta">
-                <span class="badge">{{.Project.Name}}</span>
-                <span class="badge">{{.TargetType}}</span>
-                <span class="badge">{{.ActionName}}</span>
-                <span>• aangemaakt</span>
-                <time class="timeago" datetime="{{.CreatedAt.Format "2006-01-02T15:04:05Z07:00"}}"></time>
-              </div>
-            </div>
-          {{end}}
-          </div>
-        {{else}}
-          <div class="empty">Geen open todos.</div>
-        {{end}}
-      </div>
-    </main>
-  </div>
-
-  <footer>Tip: klik op een kaart om in een nieuw tabblad te openen.</footer>
-</div>
-
-<script>
-function timeago(dt){
-  const rtf = new Intl.RelativeTimeFormat(navigator.language || 'nl-NL', {numeric:'auto'});
-  const diff = (new Date(dt) - new Date()) / 1000;
-  const abs = Math.abs(diff);
-  const units = [['year',31536000],['month',2592000],['week',604800],['day',86400],['hour',3600],['minute',60],['second',1]];
-  for (const [unit, sec] of units){
-    if (abs >= sec || unit === 'second'){ return rtf.format(Math.round(diff / sec), unit); }
-  }
-}
-function refreshTimes(){
-  document.querySelectorAll('time.timeago').forEach(t=>{
-    const dt = t.getAttribute('datetime');
-    if (dt) t.textContent = timeago(dt);
-  });
-}
-refreshTimes(); setInterval(refreshTimes, 30000); setTimeout(()=>location.reload(), 60000);
-</script>
-`))
+	for _, r := range results {
+		buf = append(buf, r...)
+	}
+	return sortMRsByUpdated(uniqMRs(buf)), errors.Join(errs...)
+}
+
+//go:embed templates
+var templateFS embed.FS
+
+var page = template.Must(parsePage(templateFS))
+
+// mrCard is what the "mrcard" template renders: an MR plus the page
+// settings it needs.
+type mrCard struct {
+	MR
+	MultiHost  bool
+	SnoozeZone string
+}
+
+func parsePage(fsys fs.FS) (*template.Template, error) {
+	return template.New("page.html").Funcs(template.FuncMap{
+		"asset": assetURL,
+		"card":  func(d dashboard, m MR) mrCard { return mrCard{m, d.MultiHost, d.SnoozeZone} },
+		"dur":   shortDuration,
+		"lang":  uiLang,
+		"t": func(key string, args ...any) string {
+			return translate(uiLang(), key, args...)
+		},
+	}).ParseFS(fsys, "templates/page.html")
+}
+
+// With DEV=1 the template is re-read from disk on every request.
+func pageTemplate() (*template.Template, error) {
+	if envBool("DEV") {
+		return parsePage(os.DirFS("."))
+	}
+	return page, nil
+}
+
+// My MRs: assigned to me or waiting for my review.
+func collectMine(ctx context.Context, cfg config, h gitlabHost) ([]MR, error) {
+	var (
+		assignee, reviewer []MR
+		errA, errR         error
+		g                  errgroup.Group
+	)
+	g.Go(func() error {
+		assignee, errA = userMRs(ctx, cfg, h, "assignee", h.User)
+		return nil
+	})
+	g.Go(func() error {
+		reviewer, errR = userMRs(ctx, cfg, h, "reviewer", h.User)
+		return nil
+	})
+	_ = g.Wait()
+	for i := range reviewer {
+		reviewer[i].FromTeam = containsUser(cfg.TeamUsers, reviewer[i].Author.Username)
+	}
+	mine := filterByAge(uniqMRs(append(reviewer, assignee...)), cfg.ageCutoff())
+	mine = filterByProjectID(mine, cfg.IncludeProjectIDs, cfg.ExcludeProjectIDs)
+	if envBool("FETCH_DISCUSSIONS") && !cfg.GraphQL {
+		mine = attachDiscussions(ctx, h.Base, h.Token, mine)
+	}
+	for i := range mine {
+		mine[i].Host = h.Name
+		mine[i].ProjectColor = projectColor(projectPath(mine[i].References.Full))
+	}
+	return mine, errors.Join(tagError("part.assigned", h.Name, errA), tagError("part.reviewer", h.Name, errR))
+}
+
+func collectTeam(ctx context.Context, cfg config, h gitlabHost) ([]MR, error) {
+	team, err := collectTeammateMRs(ctx, cfg, h, cfg.TeamUsers)
+	team = filterByProject(team, cfg.TeamProjects)
+	team = filterByAge(team, cfg.ageCutoff())
+	team = filterByProjectID(team, cfg.IncludeProjectIDs, cfg.ExcludeProjectIDs)
+	for i := range team {
+		team[i].Host = h.Name
+		team[i].ProjectColor = projectColor(projectPath(team[i].References.Full))
+	}
+	return team, err
+}
+
+// MRs I approved that are still open.
+func collectApproved(ctx context.Context, cfg config, h gitlabHost) ([]MR, error) {
+	mrs, err := fetch[MR](ctx, httpClient, approvedURL(h.Base, h.User, cfg.mrFilter()+pipelineInclude(ctx, h)), h.Token)
+	mrs = filterByAge(mrs, cfg.ageCutoff())
+	mrs = filterByProjectID(mrs, cfg.IncludeProjectIDs, cfg.ExcludeProjectIDs)
+	for i := range mrs {
+		mrs[i].Host = h.Name
+		mrs[i].ProjectColor = projectColor(projectPath(mrs[i].References.Full))
+	}
+	return mrs, err
+}
+
+func approvedURL(base, user, filter string) string {
+	return fmt.Sprintf("%s/api/v4/merge_requests?scope=all&state=opened&approved_by_usernames[]=%s&per_page=100%s", base, url.QueryEscape(user), filter)
+}
+
+func issuesURL(base, user string) string {
+	return fmt.Sprintf("%s/api/v4/issues?scope=all&state=opened&assignee_username=%s&per_page=100", base, url.QueryEscape(user))
+}
+
+// withoutMRs drops the MRs that also appear in other.
+func withoutMRs(mrs, other []MR) []MR {
+	seen := map[string]bool{}
+	for _, m := range other {
+		seen[m.Key()] = true
+	}
+	return slices.DeleteFunc(mrs, func(m MR) bool { return seen[m.Key()] })
+}
+
+func collectIssues(ctx context.Context, cfg config, h gitlabHost) ([]Issue, error) {
+	issues, err := fetch[Issue](ctx, httpClient, issuesURL(h.Base, h.User), h.Token)
+	for i := range issues {
+		issues[i].Host = h.Name
+		issues[i].ProjectColor = projectColor(projectPath(issues[i].References.Full))
+	}
+	return issues, err
+}
+
+func collectTodos(ctx context.Context, h gitlabHost) ([]Todo, error) {
+	todos, err := fetch[Todo](ctx, httpClient, fmt.Sprintf("%s/api/v4/todos?state=pending&per_page=100", h.Base), h.Token)
+	todos = attachTodoTargetStates(ctx, h.Base, h.Token, todos)
+	if envBool("HIDE_DONE_TODOS") {
+		todos = slices.DeleteFunc(todos, func(t Todo) bool { return t.TargetDone() })
+	}
+	for i := range todos {
+		todos[i].Host = h.Name
+	}
+	return todos, err
+}
+
+// The todos API usually embeds the target MR including its state; older
+// instances don't, so look those up. Issues are never looked up.
+func attachTodoTargetStates(ctx context.Context, base, token string, todos []Todo) []Todo {
+	sem := make(chan struct{}, fetchWorkers)
+	var wg sync.WaitGroup
+	for i := range todos {
+		t := todos[i]
+		if t.TargetType != "MergeRequest" || t.Target.State != "" || t.Project.ID == 0 || t.Target.IID == 0 {
+			continue
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int) {
+			defer func() { <-sem; wg.Done() }()
+			var mr struct {
+				State string `json:"state"`
+			}
+			u := fmt.Sprintf("%s/api/v4/projects/%d/merge_requests/%d", base, t.Project.ID, t.Target.IID)
+			if err := apiGet(ctx, u, token, &mr); err == nil {
+				todos[i].Target.State = mr.State
+			}
+		}(i)
+	}
+	wg.Wait()
+	return todos
+}
+
+// TargetDone reports a todo whose MR was already merged or closed.
+func (t Todo) TargetDone() bool {
+	return t.TargetType == "MergeRequest" && (t.Target.State == "merged" || t.Target.State == "closed")
+}
+
+type dashboard struct {
+	User       string
+	Base       string
+	MultiHost  bool
+	SnoozeZone string // what "snooze until" times are in
+
+	Title   string
+	Favicon template.URL
+	Accent  template.CSS
+
+	RefreshSeconds     int
+	TimeRefreshSeconds int
+
+	MRs      []MR
+	Issues   []Issue
+	Todos    []Todo
+	TeamMRs  []MR
+	Approved []MR // approved by me, still open
+
+	TodoClasses []todoClass // Todos by action required / FYI, then by day
+
+	Sections []string // main column, in order
+	ShowTeam bool
+	Teams    []string // names for the team selector
+	Team     string
+	OAuth    bool // show the logout button
+
+	Push         bool   // show the Web Push button
+	Teammates    string // OAuth: the team list, for the teammates form
+	PersonalTeam bool   // the team is the user's own list
+
+	Snoozed     int  // MRs hidden by a snooze
+	AllCaughtUp bool // no MRs, issues or todos and no errors
+	Async       bool // render skeletons; ASYNC_SECTIONS
+	Live        bool // sections are patched from /events instead of reloading
+	TeamAge     AgeStats
+	Query       string
+	TodoActions []string // active todo action filter, if any
+	Counts      sectionCounts
+	PolledAt    time.Time // when the background poller collected this
+	Failures    []failure // lists that could not be loaded
+}
+
+// filterTodosByAction keeps todos whose action_name is in actions; no
+// actions keeps everything.
+func filterTodosByAction(todos []Todo, actions []string) []Todo {
+	if len(actions) == 0 {
+		return todos
+	}
+	out := make([]Todo, 0, len(todos))
+	for _, t := range todos {
+		if slices.Contains(actions, t.ActionName) {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+// filterDashboard keeps items whose title contains q, ignoring case.
+func filterDashboard(d dashboard, q string) dashboard {
+	q = strings.TrimSpace(q)
+	d.Query = q
+	if q == "" {
+		return d
+	}
+	match := func(title string) bool {
+		return strings.Contains(strings.ToLower(title), strings.ToLower(q))
+	}
+	filterMRs := func(in []MR) []MR {
+		var out []MR
+		for _, m := range in {
+			if match(m.Title) {
+				out = append(out, m)
+			}
+		}
+		return out
+	}
+	d.MRs = filterMRs(d.MRs)
+	d.TeamMRs = filterMRs(d.TeamMRs)
+	d.Approved = filterMRs(d.Approved)
+	var issues []Issue
+	for _, i := range d.Issues {
+		if match(i.Title) {
+			issues = append(issues, i)
+		}
+	}
+	d.Issues = issues
+	var todos []Todo
+	for _, t := range d.Todos {
+		if match(t.Target.Title) {
+			todos = append(todos, t)
+		}
+	}
+	d.Todos = todos
+	return d
+}
+
+// count renders as "7", or "2 of 7" when a filter hides some items.
+type count struct{ Shown, Total int }
+
+func (c count) String() string {
+	if c.Shown == c.Total {
+		return strconv.Itoa(c.Total)
+	}
+	return fmt.Sprintf("%d of %d", c.Shown, c.Total)
+}
+
+type sectionCounts struct {
+	MRs, Issues, Todos, TeamMRs, Approved count
+}
+
+func countSections(shown, total dashboard) sectionCounts {
+	return sectionCounts{
+		MRs:      count{len(shown.MRs), len(total.MRs)},
+		Issues:   count{len(shown.Issues), len(total.Issues)},
+		Todos:    count{len(shown.Todos), len(total.Todos)},
+		TeamMRs:  count{len(shown.TeamMRs), len(total.TeamMRs)},
+		Approved: count{len(shown.Approved), len(total.Approved)},
+	}
+}
+
+// collectDashboard fetches every section from every host. Failed calls are
+// joined into the returned error; whatever did load is still returned.
+func collectDashboard(ctx context.Context, cfg config) (dashboard, error) {
+	return collectSections(ctx, cfg, cfg.Sections)
+}
+
+// dashboardShell is the part of the dashboard that needs no GitLab calls.
+func dashboardShell(cfg config) dashboard {
+	d := dashboard{
+		User:       cfg.User,
+		Base:       cfg.hostNames(),
+		MultiHost:  len(cfg.Hosts) > 1 || cfg.GitHub != nil,
+		SnoozeZone: snoozeZone(cfg).String(),
+
+		Title:   cfg.Branding.title(cfg.User),
+		Favicon: cfg.Branding.Favicon,
+		Accent:  cfg.Branding.Accent,
+
+		RefreshSeconds:     cfg.RefreshSeconds,
+		TimeRefreshSeconds: cfg.TimeRefreshSeconds,
+
+		ShowTeam: cfg.enabled("team"),
+		Team:     cfg.Team,
+		Teams:    cfg.teamNames(),
+		OAuth:    cfg.OAuth != nil,
+		Live:     polled.running(),
+
+		PersonalTeam: cfg.PersonalTeam,
+		Push:         pushes.on && polled.running(),
+	}
+	if cfg.OAuth != nil {
+		d.Teammates = strings.Join(cfg.TeamUsers, ", ")
+	}
+	for _, sec := range cfg.Sections {
+		if sec != "team" {
+			d.Sections = append(d.Sections, sec)
+		}
+	}
+	return d
+}
+
+// collectSections is collectDashboard limited to the given sections.
+func collectSections(ctx context.Context, cfg config, sections []string) (dashboard, error) {
+	d := dashboardShell(cfg)
+	want := func(sec string) bool { return slices.Contains(sections, sec) }
+	// Every provider and section is fetched concurrently. Each task
+	// writes only its own slot, so results merge in a stable order; tasks
+	// never fail the group, a failed section just comes back partial.
+	type slot struct {
+		mine, team, approved []MR
+		issues               []Issue
+		todos                []Todo
+		errs                 [5]error
+	}
+	providers := cfg.providers()
+	slots := make([]slot, len(providers))
+	var g errgroup.Group
+	g.SetLimit(fetchWorkers)
+	for i, p := range providers {
+		s := &slots[i]
+		listMRs := func(dst *[]MR, errp *error, scope string) {
+			g.Go(func() error {
+				mrs, err := p.ListMRs(ctx, cfg, scope)
+				*dst, *errp = p.ListPipelines(ctx, cfg, mrs), tagError("part."+scope, p.Name(), err)
+				return nil
+			})
+		}
+		if want("mine") {
+			listMRs(&s.mine, &s.errs[0], "mine")
+		}
+		if want("team") {
+			listMRs(&s.team, &s.errs[1], "team")
+		}
+		if want("approved") {
+			listMRs(&s.approved, &s.errs[2], "approved")
+		}
+		if want("issues") {
+			g.Go(func() error {
+				issues, err := p.ListIssues(ctx, cfg)
+				s.issues, s.errs[3] = issues, tagError("part.issues", p.Name(), err)
+				return nil
+			})
+		}
+		if want("todos") {
+			g.Go(func() error {
+				todos, err := p.ListTodos(ctx, cfg)
+				s.todos, s.errs[4] = todos, tagError("part.todos", p.Name(), err)
+				return nil
+			})
+		}
+	}
+	_ = g.Wait()
+	var errs []error
+	for _, s := range slots {
+		d.MRs = append(d.MRs, s.mine...)
+		d.TeamMRs = append(d.TeamMRs, s.team...)
+		d.Approved = append(d.Approved, s.approved...)
+		d.Issues = append(d.Issues, s.issues...)
+		d.Todos = append(d.Todos, s.todos...)
+		errs = append(errs, s.errs[:]...)
+	}
+	d.MRs = sortMRsByPriority(uniqMRs(d.MRs))
+	d.TeamMRs = sortTeamMRs(uniqMRs(d.TeamMRs), cfg.TeamSort)
+	d.Approved = sortMRsByUpdated(withoutMRs(uniqMRs(d.Approved), d.MRs))
+
+	if envBool("DEDUP_TODOS") {
+		d.Todos = filterRedundantTodos(d.Todos, append(append([]MR{}, d.MRs...), d.TeamMRs...))
+	}
+	return d, errors.Join(errs...)
+}
+
+// queryConfig applies the page's query parameters that change what is
+// fetched.
+func queryConfig(r *http.Request, cfg *config) {
+	if name := r.URL.Query().Get("team"); name != "" {
+		for _, t := range cfg.Teams {
+			if t.Name == name {
+				cfg.Team, cfg.TeamUsers = t.Name, t.Users
+			}
+		}
+	}
+	if r.URL.Query().Has("milestone") {
+		cfg.Milestone = strings.TrimSpace(r.URL.Query().Get("milestone"))
+	}
+}
+
+// prepareDashboard applies snoozes and the page's filters to a collected
+// dashboard. collectErr is what collecting returned.
+func prepareDashboard(r *http.Request, cfg config, d dashboard, collectErr error) dashboard {
+	if snoozed, err := snoozes.Active(cfg.User, time.Now()); err != nil {
+		slog.Error("snoozes", "err", err)
+	} else {
+		d.MRs = filterSnoozed(d.MRs, snoozed)
+		d.TeamMRs = filterSnoozed(d.TeamMRs, snoozed)
+		d.Approved = filterSnoozed(d.Approved, snoozed)
+		d.Snoozed = len(snoozed)
+	}
+	full := d
+	d.TeamAge = mrAgeStats(full.TeamMRs, time.Now())
+	d = filterDashboard(d, r.URL.Query().Get("q"))
+	d.TodoActions = cfg.TodoActions
+	if r.URL.Query().Has("todo_action") {
+		d.TodoActions = splitUsers(r.URL.Query().Get("todo_action"))
+	}
+	d.Todos = filterTodosByAction(d.Todos, d.TodoActions)
+	d.TodoClasses = classifyTodos(d.Todos, cfg.Location)
+	d.Counts = countSections(d, full)
+	d.Failures = failures(collectErr)
+	// Only when every call succeeded: a failed fetch is not "nothing to do".
+	d.AllCaughtUp = collectErr == nil && len(full.MRs)+len(full.Issues)+len(full.Todos)+len(full.Approved) == 0
+	return d
+}
 
 func handler(w http.ResponseWriter, r *http.Request) {
-	base := os.Getenv("GITLAB_BASE") // e.g., https://gitlab.com
-	token := os.Getenv("GITLAB_TOKEN")
-	user := os.Getenv("GITLAB_USERNAME")
-	teamEnv := os.Getenv("TEAMMATE_USERNAMES")
-	teamUsers := splitUsers(teamEnv)
-
-	if base == "" || token == "" || user == "" {
-		http.Error(w, "Set env vars: GITLAB_BASE, GITLAB_TOKEN, GITLAB_USERNAME", 500)
+	cfg, err := requestConfig(r)
+	if err != nil {
+		writeConfigError(w, r, err)
+		return
+	}
+	queryConfig(r, &cfg)
+	var d dashboard
+	if envBool("ASYNC_SECTIONS") {
+		// Sections load themselves from /api/section/{name}.
+		d = dashboardShell(cfg)
+		d.Async = true
+	} else {
+		if d, err = collectOrPolled(r.Context(), cfg, cfg.Sections); err != nil {
+			slog.Warn("gitlab", "err", errorHint(err))
+		}
+		d = prepareDashboard(r, cfg, d, err)
+	}
+	tmpl, err := pageTemplate()
+	if err != nil {
+		http.Error(w, err.Error(), 500)
 		return
 	}
+	if !envBool("DEV") {
+		etag := dashboardETag(d)
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Cache-Control", "no-cache")
+		if etagMatches(r.Header.Get("If-None-Match"), etag) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
+	// Render into a buffer so a template error becomes a clean 500 instead
+	// of half a page with status 200.
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, d); err != nil {
+		slog.Error("template", "err", err)
+		http.Error(w, "could not render dashboard", 500)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, _ = buf.WriteTo(w)
+}
+
+// Changes on every start so a new binary (and template) is never served
+// from a stale browser cache.
+var bootID = strconv.FormatInt(time.Now().UnixNano(), 36)
 
-	// My MRs
-	var assignee []MR
-	var reviewer []MR
-	_ = apiGet(fmt.Sprintf("%s/api/v4/merge_requests?scope=all&state=opened&assignee_username=%s&per_page=100&include=head_pipeline", base, user), token, &assignee)
-	_ = apiGet(fmt.Sprintf("%s/api/v4/merge_requests?scope=all&state=opened&reviewer_username=%s&per_page=100&include=head_pipeline", base, user), token, &reviewer)
-	all := uniqMRs(append(assignee, reviewer...))
-	all = attachPipelines(base, token, all)
+func dashboardETag(d dashboard) string {
+	h := sha256.New()
+	io.WriteString(h, bootID)
+	_ = json.NewEncoder(h).Encode(d)
+	return fmt.Sprintf(`"%x"`, h.Sum(nil)[:16])
+}
 
-	// Team MRs
-	teamMRs := collectTeammateMRs(base, token, teamUsers)
-	teamMRs = attachPipelines(base, token, teamMRs)
+func etagMatches(ifNoneMatch, etag string) bool {
+	for _, t := range strings.Split(ifNoneMatch, ",") {
+		t = strings.TrimPrefix(strings.TrimSpace(t), "W/")
+		if t == etag || t == "*" {
+			return true
+		}
+	}
+	return false
+}
 
-	// Todos
-	var todos []Todo
-	_ = apiGet(fmt.Sprintf("%s/api/v4/todos?state=pending&per_page=100", base), token, &todos)
-
-	_ = page.Execute(w, map[string]any{
-		"User":    user,
-		"Base":    base,
-		"MRs":     all,
-		"Todos":   todos,
-		"TeamMRs": teamMRs,
-	})
+// POST only; the cross-origin protection in main rejects forged submissions.
+func todoDoneHandler(w http.ResponseWriter, r *http.Request) {
+	cfg, err := requestConfig(r)
+	if err != nil {
+		writeConfigError(w, r, err)
+		return
+	}
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid todo id", http.StatusBadRequest)
+		return
+	}
+	h := cfg.Hosts[0]
+	if name := r.FormValue("host"); name != "" {
+		found := false
+		for _, c := range cfg.Hosts {
+			if c.Name == name {
+				h, found = c, true
+			}
+		}
+		if !found {
+			http.Error(w, "unknown host", http.StatusBadRequest)
+			return
+		}
+	}
+	if err := markTodoDone(r.Context(), h.Base, h.Token, id); err != nil {
+		slog.Warn("gitlab", "err", errorHint(err))
+		http.Error(w, "could not mark todo as done", http.StatusBadGateway)
+		return
+	}
+	polled.refresh()
+	userCache.forget(cfg.User)
+	http.Redirect(w, r, "/", http.StatusSeeOther)
 }
 
 func main() {
+	cli := flag.Bool("cli", false, "print a summary of the dashboard to stdout and exit")
+	flag.BoolVar(cli, "once", false, "same as -cli")
+	tui := flag.Bool("tui", false, "show the dashboard in the terminal, with keyboard navigation")
+	check := flag.Bool("check", false, "validate the configuration against GitLab and exit")
+	digest := flag.Bool("digest", false, "post a digest to SLACK_WEBHOOK_URL and exit")
+	encrypt := flag.Bool("encrypt-token", false, "read a token from stdin, print it encrypted with TOKEN_KEY and exit")
+	registerEnvFlags()
+	flag.Parse()
 	if err := godotenv.Load(); err != nil {
-		log.Println("No .env found or failed to load")
+		slog.Info("no .env found or failed to load")
+	}
+	if err := loadConfigFile(); err != nil {
+		fatal("config", "err", err)
+	}
+	if err := configureLogging(); err != nil {
+		fatal("config", "err", err)
+	}
+	httpClient = newHTTPClient()
+	githubClient = newGitHubClient()
+	configureSnoozes()
+	configureTeammates()
+	if err := configurePush(); err != nil {
+		fatal("config", "err", err)
+	}
+	if err := configureUserCache(); err != nil {
+		fatal("config", "err", err)
+	}
+	if err := configurePipelineCache(); err != nil {
+		fatal("config", "err", err)
+	}
+	if err := configureConcurrency(); err != nil {
+		fatal("config", "err", err)
+	}
+	if err := configurePagination(); err != nil {
+		fatal("config", "err", err)
+	}
+	if *check {
+		os.Exit(runCheck(os.Stdout))
+	}
+	if *cli {
+		os.Exit(runCLI(os.Stdout))
+	}
+	if *tui {
+		os.Exit(runTUI())
+	}
+	if *digest {
+		os.Exit(runDigest())
+	}
+	if *encrypt {
+		os.Exit(runEncryptToken(os.Stdin, os.Stdout))
+	}
+	startPprof()
+	if cfg, err := loadConfig(); err == nil {
+		probeHosts(cfg)
+		startPoller(cfg)
+		startNotifier()
 	}
 	http.HandleFunc("/", handler)
+	http.HandleFunc("POST /todos/{id}/done", todoDoneHandler)
+	http.HandleFunc("POST /mrs/snooze", snoozeHandler)
+	http.HandleFunc("POST /mrs/unsnooze", unsnoozeAllHandler)
+	http.HandleFunc("POST /settings/teammates", teammatesHandler)
+	if dashboardCredentialsSet() {
+		http.HandleFunc("POST /digest", digestHandler)
+	} else if os.Getenv("SLACK_WEBHOOK_URL") != "" {
+		slog.Info("POST /digest is off; it needs DASHBOARD_TOKEN or DASHBOARD_USER and DASHBOARD_PASS")
+	}
+	http.HandleFunc("GET /api/section/{name}", sectionHandler)
+	http.HandleFunc("GET /api/v1/dashboard", apiDashboardHandler)
+	http.HandleFunc("GET /events", eventsHandler)
+	http.HandleFunc("GET /ws", wsHandler)
+	http.HandleFunc("GET /export.csv", exportHandler)
+	http.HandleFunc("GET /stats.json", statsHandler)
+	http.HandleFunc("GET /metrics", metricsHandler)
+	http.HandleFunc("GET /feed.atom", feedHandler)
+	http.HandleFunc("GET /calendar.ics", calendarHandler)
+	http.HandleFunc("GET /sw.js", serviceWorkerHandler)
+	http.HandleFunc("GET /push/key", pushKeyHandler)
+	http.HandleFunc("POST /push/subscribe", pushSubscribeHandler)
+	http.HandleFunc("DELETE /push/subscribe", pushSubscribeHandler)
+	http.HandleFunc("GET /feed.xml", feedHandler) // the old name
+	http.Handle("GET /static/", staticHandler())
+	http.HandleFunc("GET /oauth/login", oauthLoginHandler)
+	http.HandleFunc("GET /oauth/callback", oauthCallbackHandler)
+	http.HandleFunc("POST /oauth/logout", oauthLogoutHandler)
 	port := os.Getenv("PORT")
 	if port == "" {
 		port = "8080"
+		if os.Getenv("AUTOCERT_DOMAINS") != "" {
+			port = "443"
+		}
+	}
+	slog.Info("listening", "port", port, "fetch_workers", fetchWorkers)
+	var h http.Handler = http.DefaultServeMux
+	h = refreshSessions(h)
+	h = http.NewCrossOriginProtection().Handler(h)
+	h = dashboardAuth(h)
+	root := http.NewServeMux()
+	root.Handle("/", h)
+	// Importing net/http/pprof registers it on the default mux; it is
+	// only served on its own port (DEBUG_PPROF).
+	root.Handle("/debug/pprof/", http.NotFoundHandler())
+	// Probes stay outside dashboardAuth, like the GitLab hooks.
+	root.HandleFunc("GET /healthz", healthzHandler)
+	root.HandleFunc("GET /readyz", readyzHandler)
+	root.HandleFunc("POST /hooks/gitlab", webhookHandler)
+	root.HandleFunc("POST /webhook", webhookHandler)
+	srv := &http.Server{
+		Addr:              ":" + port,
+		Handler:           serverHandler(root),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	listen, err := configureTLS(srv)
+	if err != nil {
+		fatal("config", "err", err)
+	}
+	if err := serve(srv, listen); err != nil {
+		fatal("server stopped", "err", err)
 	}
-	log.Println("listening on :" + port)
-	log.Fatal(http.ListenAndServe(":"+port, nil))
+	slog.Info("stopped")
 }
@@ -0,0 +1,303 @@
+package main
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestProjectPath(t *testing.T) {
+	for _, tt := range []struct{ in, want string }{
+		{"group/project!12", "group/project"},
+		{"group/project#4", "group/project"},
+		{"group/sub/deeper/project!7", "group/sub/deeper/project"},
+		{"group/project", "group/project"},
+		{"group/project/", "group/project"},
+		{"/group/project", "group/project"},
+		{"https://gitlab.example.com/group/sub/project", "group/sub/project"},
+		{"https://gitlab.example.com/group/sub/project/", "group/sub/project"},
+		{"https://gitlab.example.com/group/project/-/merge_requests/12", "group/project"},
+		{"https://gitlab.example.com/group/sub/project/-/issues/4#note_1", "group/sub/project"},
+		{"https://gitlab.example.com/-/profile", ""},
+		{"https://gitlab.example.com/", ""},
+		{"https://gitlab.example.com", ""},
+	} {
+		if got := projectPath(tt.in); got != tt.want {
+			t.Errorf("projectPath(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestFilterByProject(t *testing.T) {
+	mr := func(ref string) MR {
+		var m MR
+		m.References.Full = ref
+		return m
+	}
+	mrs := []MR{mr("team/api!1"), mr("team/web!2"), mr("other/api!3")}
+	got := filterByProject(mrs, []string{"Team/API/", "https://gitlab.example.com/team/web/-/tree/main"})
+	if len(got) != 2 || got[0].References.Full != "team/api!1" || got[1].References.Full != "team/web!2" {
+		t.Errorf("got %v", got)
+	}
+	if got := filterByProject(mrs, nil); len(got) != 3 {
+		t.Errorf("an empty list should keep all, got %d", len(got))
+	}
+}
+
+func TestUniqMRsAssigneeAndReviewer(t *testing.T) {
+	at := func(s string) Timestamp {
+		ts, err := parseTimestamp(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return Timestamp{ts}
+	}
+	pipe := &Pipeline{ID: 9, Status: "failed"}
+	// The same MR once as assignee (with the pipeline, updated later) and
+	// once as reviewer (with my review state); another MR between them.
+	asAssignee := MR{Host: "h", ProjectID: 1, IID: 1, Title: "new title", UpdatedAt: at("2026-03-02T10:00:00Z"), HeadPipeline: pipe}
+	other := MR{Host: "h", ProjectID: 1, IID: 2, UpdatedAt: at("2026-03-03T10:00:00Z")}
+	asReviewer := MR{Host: "h", ProjectID: 1, IID: 1, Title: "old title", UpdatedAt: at("2026-03-01T10:00:00Z"), ReviewState: "unreviewed", FromTeam: true}
+
+	got := uniqMRs([]MR{asAssignee, other, asReviewer})
+	if len(got) != 2 {
+		t.Fatalf("got %d MRs, want 2", len(got))
+	}
+	if got[0].IID != 1 || got[1].IID != 2 {
+		t.Errorf("order = %d, %d; want the first occurrence's place", got[0].IID, got[1].IID)
+	}
+	m := got[0]
+	if m.Title != "new title" || m.HeadPipeline != pipe || m.ReviewState != "unreviewed" || !m.FromTeam {
+		t.Errorf("merged MR = %+v; want the newer copy with the reviewer copy's state and team flag", m)
+	}
+
+	// The other way round gives the same MR.
+	got = uniqMRs([]MR{asReviewer, asAssignee})
+	if m := got[0]; len(got) != 1 || m.Title != "new title" || m.HeadPipeline != pipe || m.ReviewState != "unreviewed" {
+		t.Errorf("reversed: got %+v", got)
+	}
+}
+
+func TestFilterTodosByAction(t *testing.T) {
+	todos := []Todo{{ID: 1, ActionName: "assigned"}, {ID: 2, ActionName: "mentioned"}, {ID: 3, ActionName: "review_requested"}, {ID: 4, ActionName: "mentioned"}}
+	ids := func(ts []Todo) []int {
+		var out []int
+		for _, t := range ts {
+			out = append(out, t.ID)
+		}
+		return out
+	}
+	for _, tt := range []struct {
+		name    string
+		actions []string
+		want    []int
+	}{
+		{"empty filter", nil, []int{1, 2, 3, 4}},
+		{"single action", []string{"mentioned"}, []int{2, 4}},
+		{"multiple actions", []string{"review_requested", "assigned"}, []int{1, 3}},
+		{"unknown action", []string{"approval_required"}, nil},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ids(filterTodosByAction(todos, tt.actions)); !slices.Equal(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilterByAge(t *testing.T) {
+	cutoff := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	for _, tt := range []struct {
+		name    string
+		updated time.Time
+		keep    bool
+	}{
+		{"exactly at the limit", cutoff, true},
+		{"one second newer", cutoff.Add(time.Second), true},
+		{"one second older", cutoff.Add(-time.Second), false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got := filterByAge([]MR{{IID: 1, UpdatedAt: Timestamp{tt.updated}}}, cutoff)
+			if (len(got) == 1) != tt.keep {
+				t.Errorf("kept %d MRs, want keep=%v", len(got), tt.keep)
+			}
+		})
+	}
+	if got := filterByAge([]MR{{IID: 1}}, time.Time{}); len(got) != 1 {
+		t.Error("a zero cutoff should keep everything")
+	}
+}
+
+func TestAgeCutoff(t *testing.T) {
+	if c := (config{}).ageCutoff(); !c.IsZero() {
+		t.Errorf("no MAX_AGE_DAYS: cutoff = %v, want zero", c)
+	}
+	c := (config{MaxAgeDays: 3}).ageCutoff()
+	if d := time.Since(c); d < 71*time.Hour || d > 73*time.Hour {
+		t.Errorf("MAX_AGE_DAYS=3: cutoff %v ago", d)
+	}
+}
+
+func TestContainsUser(t *testing.T) {
+	team := []string{"alice", "Bob"}
+	for _, tt := range []struct {
+		users []string
+		name  string
+		want  bool
+	}{
+		{team, "alice", true},
+		{team, "bob", true}, // usernames are case-insensitive
+		{team, "ALICE", true},
+		{team, "carol", false},
+		{team, "", false},
+		{team, "alic", false},
+		{nil, "alice", false},
+		{[]string{}, "", false},
+	} {
+		if got := containsUser(tt.users, tt.name); got != tt.want {
+			t.Errorf("containsUser(%v, %q) = %v, want %v", tt.users, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestParseIDSet(t *testing.T) {
+	for _, tt := range []struct {
+		in   string
+		want []int
+		err  bool
+	}{
+		{"", nil, false},
+		{" , ,", nil, false},
+		{"12", []int{12}, false},
+		{" 12 ,7,, 12 ", []int{7, 12}, false},
+		{"12,abc", nil, true},
+		{"12;13", nil, true},
+		{"0", nil, true},
+		{"-4", nil, true},
+		{"1.5", nil, true},
+	} {
+		set, err := parseIDSet(tt.in)
+		if (err != nil) != tt.err {
+			t.Errorf("parseIDSet(%q): err = %v, want error %v", tt.in, err, tt.err)
+			continue
+		}
+		var got []int
+		for id := range set {
+			got = append(got, id)
+		}
+		slices.Sort(got)
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("parseIDSet(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestFilterByProjectID(t *testing.T) {
+	mrs := []MR{{ProjectID: 1}, {ProjectID: 2}, {ProjectID: 3}}
+	ids := func(ms []MR) []int {
+		var out []int
+		for _, m := range ms {
+			out = append(out, m.ProjectID)
+		}
+		return out
+	}
+	for _, tt := range []struct {
+		name             string
+		include, exclude map[int]bool
+		want             []int
+	}{
+		{"empty sets keep all", nil, map[int]bool{}, []int{1, 2, 3}},
+		{"include", map[int]bool{1: true, 3: true}, nil, []int{1, 3}},
+		{"exclude", nil, map[int]bool{2: true}, []int{1, 3}},
+		{"exclusion wins", map[int]bool{1: true, 2: true}, map[int]bool{2: true}, []int{1}},
+		{"unknown include", map[int]bool{9: true}, nil, nil},
+	} {
+		if got := ids(filterByProjectID(mrs, tt.include, tt.exclude)); !slices.Equal(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestApprovedURL(t *testing.T) {
+	got := approvedURL("https://gitlab.example.com", "jane.doe+bot", "&labels=a%2Cb&include=head_pipeline")
+	want := "https://gitlab.example.com/api/v4/merge_requests?scope=all&state=opened&approved_by_usernames[]=jane.doe%2Bbot&per_page=100&labels=a%2Cb&include=head_pipeline"
+	if got != want {
+		t.Errorf("approvedURL:\n got %s\nwant %s", got, want)
+	}
+}
+
+func TestIssuesURL(t *testing.T) {
+	got := issuesURL("https://gitlab.example.com", "jane.doe+bot")
+	want := "https://gitlab.example.com/api/v4/issues?scope=all&state=opened&assignee_username=jane.doe%2Bbot&per_page=100"
+	if got != want {
+		t.Errorf("issuesURL:\n got %s\nwant %s", got, want)
+	}
+}
+
+func TestSortTeamMRsTies(t *testing.T) {
+	day := func(d int) Timestamp { return Timestamp{time.Date(2026, 3, d, 0, 0, 0, 0, time.UTC)} }
+	mr := func(iid int, author, ref string, updated Timestamp) MR {
+		m := MR{IID: iid, UpdatedAt: updated}
+		m.Author.Name = author
+		m.References.Full = ref
+		return m
+	}
+	order := func(mrs []MR) []int {
+		var out []int
+		for _, m := range mrs {
+			out = append(out, m.IID)
+		}
+		return out
+	}
+	// 2 and 3 share an author, project and update time: only their input
+	// order can break the tie.
+	in := []MR{
+		mr(1, "Bob", "g/web!1", day(1)),
+		mr(2, "alice", "g/api!2", day(2)),
+		mr(3, "Alice", "G/API!3", day(2)),
+		mr(4, "alice", "g/api!4", day(5)),
+	}
+	for _, tt := range []struct {
+		by   string
+		want []int
+	}{
+		{"updated", []int{4, 2, 3, 1}},
+		{"author", []int{4, 2, 3, 1}},
+		{"project", []int{4, 2, 3, 1}},
+	} {
+		t.Run(tt.by, func(t *testing.T) {
+			for range 5 {
+				if got := order(sortTeamMRs(slices.Clone(in), tt.by)); !slices.Equal(got, tt.want) {
+					t.Fatalf("got %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+	// Swapping the tied pair in the input swaps it in the output.
+	swapped := []MR{in[0], in[2], in[1], in[3]}
+	if got := order(sortTeamMRs(swapped, "author")); !slices.Equal(got, []int{4, 3, 2, 1}) {
+		t.Errorf("swapped input: got %v", got)
+	}
+}
+
+func TestSortMRsByPriorityTies(t *testing.T) {
+	day := func(d int) Timestamp { return Timestamp{time.Date(2026, 3, d, 0, 0, 0, 0, time.UTC)} }
+	failed := &Pipeline{Status: "failed"}
+	in := []MR{
+		{IID: 1, UpdatedAt: day(1)},
+		{IID: 2, UpdatedAt: day(3), HasConflicts: true},
+		{IID: 3, UpdatedAt: day(2), HeadPipeline: failed},
+		{IID: 4, UpdatedAt: day(2), HeadPipeline: failed},
+		{IID: 5, UpdatedAt: day(1), HasConflicts: true, HeadPipeline: failed},
+		{IID: 6, UpdatedAt: day(1)},
+	}
+	var got []int
+	for _, m := range sortMRsByPriority(in) {
+		got = append(got, m.IID)
+	}
+	// Failed first, then conflicts, then the rest; least recently updated
+	// first within each, and input order on equal times.
+	if want := []int{5, 3, 4, 2, 1, 6}; !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
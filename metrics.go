@@ -0,0 +1,210 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// GET /metrics: Prometheus text format, written by hand; the handful of
+// series here don't warrant a client library. The GitLab series count
+// what the dashboard asked for: one request per call, however many
+// retries or cache revalidations it took.
+
+var latencyBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+type endpointKey struct {
+	Host, Endpoint, Code string // Code is "error" when no response came back
+}
+
+type latency struct {
+	buckets []int64 // cumulative counts per latencyBuckets
+	count   int64
+	sum     float64
+}
+
+var requestMetrics = struct {
+	sync.Mutex
+	counts    map[endpointKey]int64
+	latencies map[[2]string]*latency // host, endpoint
+}{counts: map[endpointKey]int64{}, latencies: map[[2]string]*latency{}}
+
+// metricsTransport records every GitLab call in requestMetrics and logs
+// it: failures at warn, the rest at debug. Requests to other hosts pass
+// through untouched, so their paths never end up in labels or logs.
+type metricsTransport struct {
+	next  http.RoundTripper
+	hosts map[string]bool // the GitLab hosts
+}
+
+func (t *metricsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !t.hosts[req.URL.Host] {
+		return t.next.RoundTrip(req)
+	}
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	took := time.Since(start)
+	code := "error"
+	attrs := []any{"method", req.Method, "url", redactURL(req.URL.String()), "duration", took}
+	switch {
+	case err != nil:
+		slog.Warn("gitlab request failed", append(attrs, "err", err)...)
+	case resp.StatusCode >= 400:
+		slog.Warn("gitlab request failed", append(attrs, "status", resp.StatusCode)...)
+	default:
+		slog.Debug("gitlab request", append(attrs, "status", resp.StatusCode)...)
+	}
+	if err == nil {
+		code = fmt.Sprint(resp.StatusCode)
+		noteUpstream(resp.StatusCode)
+	}
+	observeRequest(req.URL.Host, endpointLabel(req.URL.EscapedPath()), code, took)
+	return resp, err
+}
+
+func observeRequest(host, endpoint, code string, d time.Duration) {
+	requestMetrics.Lock()
+	defer requestMetrics.Unlock()
+	requestMetrics.counts[endpointKey{host, endpoint, code}]++
+	k := [2]string{host, endpoint}
+	l := requestMetrics.latencies[k]
+	if l == nil {
+		l = &latency{buckets: make([]int64, len(latencyBuckets))}
+		requestMetrics.latencies[k] = l
+	}
+	secs := d.Seconds()
+	for i, b := range latencyBuckets {
+		if secs <= b {
+			l.buckets[i]++
+		}
+	}
+	l.count++
+	l.sum += secs
+}
+
+// IDs, and project paths (URL-encoded, so one segment).
+var idSegment = regexp.MustCompile(`/(\d+|[^/]*%2[Ff][^/]*)(/|$)`)
+
+// endpointLabel turns an escaped path into a label with bounded values:
+// /api/v4/projects/12/merge_requests/3/pipelines becomes
+// /projects/:id/merge_requests/:id/pipelines.
+func endpointLabel(path string) string {
+	path = strings.TrimPrefix(path, "/api/v4")
+	// Twice: the regexp can't match adjacent IDs in one pass.
+	for range 2 {
+		path = idSegment.ReplaceAllString(path, "/:id$2")
+	}
+	return path
+}
+
+func metricsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	writeRequestMetrics(w)
+
+	c := currentClientStats()
+	counter(w, "homepager_gitlab_retries_total", "Requests sent again after a transient error.", c.Retries)
+	counter(w, "homepager_gitlab_rate_limited_total", "429 responses.", c.RateLimited)
+	counter(w, "homepager_gitlab_paused_total", "Requests held back until the rate limit reset.", c.Paused)
+	counter(w, "homepager_etag_not_modified_total", "Responses answered from the ETag cache after a 304.", c.NotModified)
+	fmt.Fprintf(w, "# HELP homepager_pipeline_cache_lookups_total Pipeline cache lookups by result.\n# TYPE homepager_pipeline_cache_lookups_total counter\n")
+	fmt.Fprintf(w, "homepager_pipeline_cache_lookups_total{result=\"hit\"} %d\n", c.PipelineHits)
+	fmt.Fprintf(w, "homepager_pipeline_cache_lookups_total{result=\"miss\"} %d\n", c.PipelineMisses)
+
+	writeDashboardMetrics(w)
+}
+
+func counter(w io.Writer, name, help string, v int64) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n%s %d\n", name, help, name, name, v)
+}
+
+func writeRequestMetrics(w io.Writer) {
+	requestMetrics.Lock()
+	defer requestMetrics.Unlock()
+	keys := make([]endpointKey, 0, len(requestMetrics.counts))
+	for k := range requestMetrics.counts {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		return a.Host+a.Endpoint+a.Code < b.Host+b.Endpoint+b.Code
+	})
+	fmt.Fprint(w, "# HELP homepager_gitlab_requests_total GitLab API calls by host, endpoint and status code.\n# TYPE homepager_gitlab_requests_total counter\n")
+	for _, k := range keys {
+		fmt.Fprintf(w, "homepager_gitlab_requests_total{host=%q,endpoint=%q,code=%q} %d\n", k.Host, k.Endpoint, k.Code, requestMetrics.counts[k])
+	}
+	fmt.Fprint(w, "# HELP homepager_gitlab_request_errors_total GitLab API calls that failed or got a 4xx/5xx.\n# TYPE homepager_gitlab_request_errors_total counter\n")
+	errs := map[[2]string]int64{}
+	for _, k := range keys {
+		if k.Code == "error" || k.Code >= "400" {
+			errs[[2]string{k.Host, k.Endpoint}] += requestMetrics.counts[k]
+		}
+	}
+	for _, k := range sortedPairs(errs) {
+		fmt.Fprintf(w, "homepager_gitlab_request_errors_total{host=%q,endpoint=%q} %d\n", k[0], k[1], errs[k])
+	}
+	fmt.Fprint(w, "# HELP homepager_gitlab_request_duration_seconds GitLab API call latency, retries included.\n# TYPE homepager_gitlab_request_duration_seconds histogram\n")
+	for _, k := range sortedPairs(requestMetrics.latencies) {
+		l := requestMetrics.latencies[k]
+		labels := fmt.Sprintf("host=%q,endpoint=%q", k[0], k[1])
+		for i, b := range latencyBuckets {
+			fmt.Fprintf(w, "homepager_gitlab_request_duration_seconds_bucket{%s,le=\"%g\"} %d\n", labels, b, l.buckets[i])
+		}
+		fmt.Fprintf(w, "homepager_gitlab_request_duration_seconds_bucket{%s,le=\"+Inf\"} %d\n", labels, l.count)
+		fmt.Fprintf(w, "homepager_gitlab_request_duration_seconds_sum{%s} %g\n", labels, l.sum)
+		fmt.Fprintf(w, "homepager_gitlab_request_duration_seconds_count{%s} %d\n", labels, l.count)
+	}
+}
+
+func sortedPairs[V any](m map[[2]string]V) [][2]string {
+	keys := make([][2]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i][0]+keys[i][1] < keys[j][0]+keys[j][1] })
+	return keys
+}
+
+// writeDashboardMetrics reports the dashboard itself, from the poller's
+// snapshot; without POLL_SECONDS a scrape would cost a full collect, so
+// these are left out. With the OAuth login there is no one dashboard to
+// report on.
+func writeDashboardMetrics(w io.Writer) {
+	cfg, err := loadConfig()
+	if err != nil || cfg.OAuth != nil {
+		return
+	}
+	d, ok, err := polled.get(cfg)
+	if !ok {
+		return
+	}
+	if err != nil {
+		slog.Warn("metrics: dashboard incomplete", "err", errorHint(err))
+	}
+	s := dashboardStats(d)
+	gauge(w, "homepager_open_mrs", "MRs assigned to me or waiting for my review.", s.OpenMRs)
+	gauge(w, "homepager_pending_todos", "Pending todos.", s.PendingTodos)
+	gauge(w, "homepager_failing_pipelines", "My MRs with a failed pipeline.", s.FailingPipelines)
+	gauge(w, "homepager_team_mrs", "Open MRs of the team.", len(d.TeamMRs))
+
+	fs := failures(err)
+	fmt.Fprint(w, "# HELP homepager_gitlab_up Whether the last collection reached the host without errors.\n# TYPE homepager_gitlab_up gauge\n")
+	for _, h := range cfg.Hosts {
+		up := 1
+		if slices.ContainsFunc(fs, func(f failure) bool { return f.Host == h.Name || f.Host == "" }) {
+			up = 0
+		}
+		fmt.Fprintf(w, "homepager_gitlab_up{host=%q} %d\n", h.Name, up)
+	}
+	fmt.Fprintf(w, "# HELP homepager_last_poll_timestamp_seconds When the background poller last collected.\n# TYPE homepager_last_poll_timestamp_seconds gauge\nhomepager_last_poll_timestamp_seconds %d\n", d.PolledAt.Unix())
+}
+
+func gauge(w io.Writer, name, help string, v int) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n%s %d\n", name, help, name, name, v)
+}
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestEndpointLabel(t *testing.T) {
+	for _, tt := range []struct{ url, want string }{
+		{"https://gitlab.example.com/api/v4/projects/12/merge_requests/3/pipelines", "/projects/:id/merge_requests/:id/pipelines"},
+		{"https://gitlab.example.com/api/v4/projects/group%2Fsub%2Fproj/merge_requests", "/projects/:id/merge_requests"},
+		{"https://gitlab.example.com/api/v4/projects/12/34", "/projects/:id/:id"},
+		{"https://gitlab.example.com/api/v4/todos?state=pending", "/todos"},
+	} {
+		u, err := url.Parse(tt.url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := endpointLabel(u.EscapedPath()); got != tt.want {
+			t.Errorf("endpointLabel(%q) = %q, want %q", u.EscapedPath(), got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,362 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Notification events, as named in NOTIFY_EVENTS.
+var notifyKinds = []string{"review_requested", "todo", "pipeline_failed", "merged"}
+
+// notifyEvent is one thing worth telling someone about.
+type notifyEvent struct {
+	Kind  string    `json:"event"`
+	Title string    `json:"title"` // of the MR or todo target
+	URL   string    `json:"url"`
+	Ref   string    `json:"ref,omitempty"` // e.g. group/project!12
+	By    string    `json:"author,omitempty"`
+	Extra string    `json:"pipeline_url,omitempty"`
+	At    time.Time `json:"at"`
+}
+
+func (e notifyEvent) headline() string {
+	switch e.Kind {
+	case "review_requested":
+		return "Review requested"
+	case "todo":
+		return "New todo"
+	case "pipeline_failed":
+		return "Pipeline failed"
+	case "merged":
+		return "Merged"
+	}
+	return e.Kind
+}
+
+// Sink is a place notifications are posted to.
+type Sink interface {
+	Name() string
+	Send(ctx context.Context, e notifyEvent) error
+}
+
+// configuredSinks lists a sink for every webhook URL that is set. Slack's
+// webhook doubles as the digest's, so it only gets notifications with
+// SLACK_NOTIFY_FAILURES.
+func configuredSinks() []Sink {
+	var sinks []Sink
+	if u := os.Getenv("SLACK_WEBHOOK_URL"); u != "" && envBool("SLACK_NOTIFY_FAILURES") {
+		sinks = append(sinks, slackSink{u})
+	}
+	if u := os.Getenv("DISCORD_WEBHOOK_URL"); u != "" {
+		sinks = append(sinks, discordSink{u})
+	}
+	if u := os.Getenv("TEAMS_WEBHOOK_URL"); u != "" {
+		sinks = append(sinks, teamsSink{u})
+	}
+	if u := os.Getenv("NOTIFY_WEBHOOK_URL"); u != "" {
+		sinks = append(sinks, jsonSink{u})
+	}
+	return sinks
+}
+
+// notifyKindsEnv reads a list of events, like NOTIFY_EVENTS.
+func notifyKindsEnv(name string, def ...string) []string {
+	kinds := splitUsers(os.Getenv(name))
+	if kinds == nil {
+		kinds = def
+	}
+	for _, k := range kinds {
+		if !slices.Contains(notifyKinds, k) {
+			fatal("config", "err", fmt.Sprintf("%s: unknown event %q, expected some of %s", name, k, strings.Join(notifyKinds, ",")))
+		}
+	}
+	return kinds
+}
+
+// startNotifier sends the NOTIFY_EVENTS (default pipeline_failed) to the
+// configured webhooks, and the PUSH_EVENTS (default review_requested,todo)
+// to browsers with WEB_PUSH. MR events only count for MRs in
+// NOTIFY_SECTIONS (default mine,team) and, when set, by NOTIFY_USERS. It
+// compares the poller's snapshots, so it needs POLL_SECONDS; a GitLab
+// webhook makes it immediate.
+func startNotifier() {
+	sinks := configuredSinks()
+	if len(sinks) == 0 && !pushes.on {
+		return
+	}
+	if !polled.running() {
+		slog.Warn("notifications need POLL_SECONDS; none will be sent")
+		return
+	}
+	kinds := map[Sink][]string{}
+	for _, s := range sinks {
+		kinds[s] = notifyKindsEnv("NOTIFY_EVENTS", "pipeline_failed")
+	}
+	if pushes.on {
+		kinds[pushSink{}] = notifyKindsEnv("PUSH_EVENTS", "review_requested", "todo")
+	}
+	sections := splitUsers(envFirst("NOTIFY_SECTIONS", "SLACK_NOTIFY_SECTIONS"))
+	if sections == nil {
+		sections = []string{"mine", "team"}
+	}
+	for _, sec := range sections {
+		if !slices.Contains([]string{"mine", "team", "approved"}, sec) {
+			fatal("config", "err", fmt.Sprintf("NOTIFY_SECTIONS: unknown section %q, expected some of mine,team,approved", sec))
+		}
+	}
+	users := splitUsers(envFirst("NOTIFY_USERS", "SLACK_NOTIFY_USERS"))
+	for s, k := range kinds {
+		slog.Info("notifications on", "sink", s.Name(), "events", strings.Join(k, ","))
+	}
+
+	polls, stop := polled.subscribe()
+	polled.wg.Add(1)
+	go func() {
+		defer polled.wg.Done()
+		defer stop()
+		var prev dashboard
+		primed := false
+		for {
+			select {
+			case <-polls:
+			case <-polled.done:
+				return
+			}
+			cfg, next, err := polled.snapshot()
+			if !primed {
+				// Everything is new on the first poll; that's no news.
+				prev, primed = next, true
+				continue
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			events := diffEvents(ctx, cfg, prev, next, err == nil, sections, users)
+			prev = next
+			for _, e := range events {
+				for s, k := range kinds {
+					if !slices.Contains(k, e.Kind) {
+						continue
+					}
+					if err := s.Send(ctx, e); err != nil {
+						slog.Warn("notification failed", "sink", s.Name(), "event", e.Kind, "err", errorHint(err))
+					}
+				}
+			}
+			cancel()
+		}
+	}()
+}
+
+// diffEvents lists what happened between two snapshots. An MR that left
+// the lists is looked up, since it may as well have been closed or have
+// lost me as a reviewer; that is only trusted when next is complete.
+func diffEvents(ctx context.Context, cfg config, prev, next dashboard, complete bool, sections, users []string) []notifyEvent {
+	now := time.Now()
+	inSections := func(d dashboard, key string) bool {
+		for _, sec := range sections {
+			list := map[string][]MR{"mine": d.MRs, "team": d.TeamMRs, "approved": d.Approved}[sec]
+			if slices.ContainsFunc(list, func(m MR) bool { return m.Key() == key }) {
+				return true
+			}
+		}
+		return false
+	}
+	watched := func(d dashboard, m MR) bool {
+		return inSections(d, m.Key()) && (len(users) == 0 || containsUser(users, m.Author.Username))
+	}
+	mrEvent := func(kind string, m MR) notifyEvent {
+		return notifyEvent{Kind: kind, Title: m.Title, URL: m.WebURL, Ref: m.References.Full, By: m.Author.Name, At: now}
+	}
+
+	var out []notifyEvent
+	before := map[string]MR{}
+	for _, m := range dashboardMRs(prev) {
+		before[m.Key()] = m
+	}
+	for _, ch := range diffPipelines(prev, next, complete) {
+		m := ch.MR
+		switch {
+		case !ch.Removed && m.HeadPipeline != nil && m.HeadPipeline.Status == "failed" && watched(next, m):
+			e := mrEvent("pipeline_failed", m)
+			e.Extra = m.HeadPipeline.WebURL
+			out = append(out, e)
+		case ch.Removed && m.Provider == "" && watched(prev, m) && mrMerged(ctx, cfg, m):
+			out = append(out, mrEvent("merged", m))
+		}
+	}
+	for _, m := range next.MRs {
+		if _, ok := before[m.Key()]; !ok && m.ReviewState != "" {
+			out = append(out, mrEvent("review_requested", m))
+		}
+	}
+	seen := map[string]bool{}
+	for _, t := range prev.Todos {
+		seen[fmt.Sprintf("%s:%d", t.Host, t.ID)] = true
+	}
+	for _, t := range next.Todos {
+		if !seen[fmt.Sprintf("%s:%d", t.Host, t.ID)] {
+			out = append(out, notifyEvent{
+				Kind: "todo", Title: t.Target.Title, URL: t.Target.WebURL,
+				Ref: t.Project.Name + " · " + t.ActionName, By: t.Author.Name, At: now,
+			})
+		}
+	}
+	return out
+}
+
+// mrMerged looks up whether an MR that left the dashboard was merged.
+func mrMerged(ctx context.Context, cfg config, m MR) bool {
+	i := slices.IndexFunc(cfg.Hosts, func(h gitlabHost) bool { return h.Name == m.Host })
+	if i < 0 {
+		return false
+	}
+	h := cfg.Hosts[i]
+	var got struct {
+		State string `json:"state"`
+	}
+	u := fmt.Sprintf("%s/api/v4/projects/%d/merge_requests/%d", h.Base, m.ProjectID, m.IID)
+	if err := apiGet(ctx, u, h.Token, &got); err != nil {
+		slog.Warn("notifications: MR lookup failed", "mr", m.Key(), "err", errorHint(err))
+		return false
+	}
+	return got.State == "merged"
+}
+
+// envFirst is the first of the env vars that is set.
+func envFirst(names ...string) string {
+	for _, n := range names {
+		if v := os.Getenv(n); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// webhookClient posts to Slack, Discord, Teams and the like. It is not
+// httpClient: GITLAB_INSECURE, GitLab's retries and its metrics have no
+// business here.
+var webhookClient = &http.Client{Timeout: 10 * time.Second}
+
+// webhookHost is all of a webhook URL that is safe to log; the path
+// holds the secret.
+func webhookHost(raw string) string {
+	if u, err := url.Parse(raw); err == nil && u.Host != "" {
+		return u.Host
+	}
+	return "webhook"
+}
+
+// postJSON posts body to a webhook. Its errors only name the host.
+func postJSON(ctx context.Context, u string, body any) error {
+	b, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", u, bytes.NewReader(b))
+	if err != nil {
+		return fmt.Errorf("%s: invalid webhook URL", webhookHost(u))
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := webhookClient.Do(req)
+	if err != nil {
+		var uerr *url.Error
+		if errors.As(err, &uerr) {
+			err = uerr.Err
+		}
+		return fmt.Errorf("POST %s: %w", webhookHost(u), err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("POST %s -> %d %s", webhookHost(u), resp.StatusCode, http.StatusText(resp.StatusCode))
+	}
+	return nil
+}
+
+type slackSink struct{ url string }
+
+func (slackSink) Name() string { return "slack" }
+
+func (s slackSink) Send(ctx context.Context, e notifyEvent) error {
+	icon := map[string]string{"pipeline_failed": ":red_circle:", "merged": ":white_check_mark:", "review_requested": ":eyes:", "todo": ":bell:"}[e.Kind]
+	text := fmt.Sprintf("%s %s: %s", icon, e.headline(), slackLink(e.URL, e.Title))
+	if e.Ref != "" {
+		text += " (" + slackEscaper.Replace(e.Ref) + ")"
+	}
+	if e.By != "" {
+		text += " by " + slackEscaper.Replace(e.By)
+	}
+	if e.Extra != "" {
+		text += " – " + slackLink(e.Extra, "pipeline")
+	}
+	return postSlack(ctx, s.url, slackMessage{
+		Text:   e.headline() + ": " + e.Title,
+		Blocks: []slackBlock{{Type: "section", Text: &slackText{"mrkdwn", text}}},
+	})
+}
+
+// markdownLine is the event as a line of Markdown, for Discord and Teams.
+func (e notifyEvent) markdownLine() string {
+	esc := strings.NewReplacer("[", `\[`, "]", `\]`, "*", `\*`, "_", `\_`).Replace
+	text := fmt.Sprintf("**%s**: [%s](%s)", e.headline(), esc(e.Title), e.URL)
+	if e.Ref != "" {
+		text += " (" + esc(e.Ref) + ")"
+	}
+	if e.By != "" {
+		text += " by " + esc(e.By)
+	}
+	if e.Extra != "" {
+		text += " – [pipeline](" + e.Extra + ")"
+	}
+	return text
+}
+
+type discordSink struct{ url string }
+
+func (discordSink) Name() string { return "discord" }
+
+func (s discordSink) Send(ctx context.Context, e notifyEvent) error {
+	return postJSON(ctx, s.url, map[string]any{
+		"content":          e.markdownLine(),
+		"allowed_mentions": map[string]any{"parse": []string{}},
+	})
+}
+
+// teamsSink posts an Adaptive Card, which both Workflows webhooks and the
+// older connectors take.
+type teamsSink struct{ url string }
+
+func (teamsSink) Name() string { return "teams" }
+
+func (s teamsSink) Send(ctx context.Context, e notifyEvent) error {
+	card := map[string]any{
+		"type":    "AdaptiveCard",
+		"$schema": "http://adaptivecards.io/schemas/adaptive-card.json",
+		"version": "1.4",
+		"body":    []any{map[string]any{"type": "TextBlock", "text": e.markdownLine(), "wrap": true}},
+	}
+	return postJSON(ctx, s.url, map[string]any{
+		"type": "message",
+		"attachments": []any{map[string]any{
+			"contentType": "application/vnd.microsoft.card.adaptive",
+			"content":     card,
+		}},
+	})
+}
+
+// jsonSink posts the event as is, for anything else.
+type jsonSink struct{ url string }
+
+func (jsonSink) Name() string { return "webhook" }
+
+func (s jsonSink) Send(ctx context.Context, e notifyEvent) error {
+	return postJSON(ctx, s.url, e)
+}
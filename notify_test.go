@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSlackSinkVerifiesTLS(t *testing.T) {
+	t.Setenv("GITLAB_INSECURE", "1")
+	old := httpClient
+	httpClient = newHTTPClient()
+	defer func() { httpClient = old }()
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	err := slackSink{srv.URL + "/services/T000/B000/s3cr3t"}.Send(context.Background(), notifyEvent{Kind: "pipeline_failed", Title: "x"})
+	if err == nil {
+		t.Fatal("a self-signed webhook was accepted; GITLAB_INSECURE must not apply to Slack")
+	}
+	if strings.Contains(err.Error(), "s3cr3t") {
+		t.Errorf("error leaks the webhook path: %v", err)
+	}
+}
+
+func TestSinksHideWebhookPath(t *testing.T) {
+	var got []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = append(got, r.URL.Path)
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer srv.Close()
+
+	for _, s := range []Sink{
+		discordSink{srv.URL + "/api/webhooks/1/s3cr3t"},
+		teamsSink{srv.URL + "/webhookb2/s3cr3t"},
+		jsonSink{srv.URL + "/hook/s3cr3t"},
+	} {
+		err := s.Send(context.Background(), notifyEvent{Kind: "merged", Title: "x"})
+		if err == nil {
+			t.Errorf("%s: want an error for a 403", s.Name())
+			continue
+		}
+		if strings.Contains(err.Error(), "s3cr3t") {
+			t.Errorf("%s: error leaks the webhook path: %v", s.Name(), err)
+		}
+	}
+	if len(got) != 3 {
+		t.Errorf("server got %d posts, want 3", len(got))
+	}
+}
@@ -0,0 +1,320 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	sessionCookie = "homepager_session"
+	stateCookie   = "homepager_oauth_state"
+)
+
+var errNoSession = errors.New("not logged in")
+
+// oauthConfig enables the GitLab OAuth login. Visitors then use their own
+// token instead of GITLAB_TOKEN.
+type oauthConfig struct {
+	ClientID      string
+	ClientSecret  string
+	RedirectURL   string
+	SessionSecret []byte
+}
+
+// loadOAuthConfig returns nil when OAuth isn't configured.
+func loadOAuthConfig() (*oauthConfig, error) {
+	o := &oauthConfig{
+		ClientID:      os.Getenv("GITLAB_OAUTH_CLIENT_ID"),
+		ClientSecret:  strings.TrimSpace(os.Getenv("GITLAB_OAUTH_CLIENT_SECRET")),
+		RedirectURL:   os.Getenv("GITLAB_OAUTH_REDIRECT_URL"),
+		SessionSecret: []byte(os.Getenv("SESSION_SECRET")),
+	}
+	if o.ClientID == "" && o.ClientSecret == "" && o.RedirectURL == "" {
+		return nil, nil
+	}
+	if o.ClientID == "" || o.ClientSecret == "" || o.RedirectURL == "" {
+		return nil, fmt.Errorf("Set env vars: GITLAB_OAUTH_CLIENT_ID, GITLAB_OAUTH_CLIENT_SECRET, GITLAB_OAUTH_REDIRECT_URL")
+	}
+	var err error
+	if o.ClientSecret, err = openSecret(o.ClientSecret); err != nil {
+		return nil, fmt.Errorf("GITLAB_OAUTH_CLIENT_SECRET: %w", err)
+	}
+	if len(o.SessionSecret) < 32 {
+		return nil, fmt.Errorf("SESSION_SECRET must be at least 32 characters when OAuth is enabled")
+	}
+	return o, nil
+}
+
+type session struct {
+	Token    string    `json:"t"`
+	Username string    `json:"u"`
+	Expires  time.Time `json:"e"` // of Token
+	Refresh  string    `json:"r,omitempty"`
+	Until    time.Time `json:"l,omitempty"` // of the session, when Refresh is set
+}
+
+// How long a session can be kept alive with its refresh token.
+const sessionLifetime = 30 * 24 * time.Hour
+
+// stale reports an access token that needs refreshing first.
+func (s session) stale() bool { return time.Now().After(s.Expires) }
+
+func (s session) cookieExpires() time.Time {
+	if s.Refresh != "" {
+		return s.Until
+	}
+	return s.Expires
+}
+
+// Sessions are encrypted, not just signed: the refresh token in them stays
+// good for long after the cookie leaks.
+func (o *oauthConfig) encodeSession(s session) string {
+	b, _ := json.Marshal(s)
+	return base64.RawURLEncoding.EncodeToString(seal(o.sessionKey(), b))
+}
+
+func (o *oauthConfig) decodeSession(v string) (session, error) {
+	var s session
+	box, err := base64.RawURLEncoding.DecodeString(v)
+	if err != nil {
+		return s, errNoSession
+	}
+	b, ok := unseal(o.sessionKey(), box)
+	if !ok {
+		return s, errNoSession // also the signed cookies from before
+	}
+	if err := json.Unmarshal(b, &s); err != nil || time.Now().After(s.cookieExpires()) {
+		return s, errNoSession
+	}
+	return s, nil
+}
+
+func (o *oauthConfig) sessionCookie(s session) *http.Cookie {
+	return &http.Cookie{
+		Name:     sessionCookie,
+		Value:    o.encodeSession(s),
+		Path:     "/",
+		Expires:  s.cookieExpires(),
+		HttpOnly: true,
+		Secure:   o.secureCookies(),
+		SameSite: http.SameSiteLaxMode,
+	}
+}
+
+// refreshSessions renews an expired access token with the session's
+// refresh token before next sees the request, so visitors stay logged in
+// past GitLab's two-hour token lifetime. The config is read once, like
+// dashboardAuth reads its env; without OAuth it returns next unchanged.
+func refreshSessions(next http.Handler) http.Handler {
+	cfg, err := loadConfig()
+	if err != nil || cfg.OAuth == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := r.Cookie(sessionCookie)
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		s, err := cfg.OAuth.decodeSession(c.Value)
+		if err != nil || !s.stale() || s.Refresh == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		tok, err := requestToken(r.Context(), cfg.Hosts[0].Base, url.Values{
+			"client_id":     {cfg.OAuth.ClientID},
+			"client_secret": {cfg.OAuth.ClientSecret},
+			"refresh_token": {s.Refresh},
+			"grant_type":    {"refresh_token"},
+			"redirect_uri":  {cfg.OAuth.RedirectURL},
+		})
+		if err != nil {
+			// requestConfig sees the stale token and sends them to the login.
+			slog.Warn("oauth refresh failed", "user", s.Username, "err", errorHint(err))
+			next.ServeHTTP(w, r)
+			return
+		}
+		s.Token, s.Expires = tok.AccessToken, tok.expires()
+		if tok.RefreshToken != "" {
+			s.Refresh = tok.RefreshToken // GitLab rotates them
+		}
+		nc := cfg.OAuth.sessionCookie(s)
+		http.SetCookie(w, nc)
+		others := slices.DeleteFunc(r.Cookies(), func(k *http.Cookie) bool { return k.Name == sessionCookie })
+		r = r.Clone(r.Context())
+		r.Header.Del("Cookie")
+		for _, k := range others {
+			r.AddCookie(k)
+		}
+		r.AddCookie(&http.Cookie{Name: nc.Name, Value: nc.Value})
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (o *oauthConfig) secureCookies() bool {
+	return strings.HasPrefix(o.RedirectURL, "https://")
+}
+
+// requestConfig is loadConfig plus, with OAuth, the visitor's own token and
+// username taken from the session cookie.
+func requestConfig(r *http.Request) (config, error) {
+	cfg, err := loadConfig()
+	if err != nil || cfg.OAuth == nil {
+		return cfg, err
+	}
+	c, err := r.Cookie(sessionCookie)
+	if err != nil {
+		return cfg, errNoSession
+	}
+	s, err := cfg.OAuth.decodeSession(c.Value)
+	if err != nil {
+		return cfg, err
+	}
+	if s.stale() {
+		return cfg, errNoSession
+	}
+	cfg.User = s.Username
+	cfg.Hosts[0].User = s.Username
+	cfg.Hosts[0].Token = "Bearer " + s.Token
+	personalTeam(&cfg)
+	return cfg, nil
+}
+
+// writeConfigError answers a failed requestConfig. Anonymous visitors of
+// the dashboard are sent to the login.
+func writeConfigError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, errNoSession) {
+		if r.Method == "GET" && r.URL.Path == "/" {
+			http.Redirect(w, r, "/oauth/login", http.StatusFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	http.Error(w, err.Error(), 500)
+}
+
+func oauthLoginHandler(w http.ResponseWriter, r *http.Request) {
+	cfg, err := loadConfig()
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	if cfg.OAuth == nil {
+		http.NotFound(w, r)
+		return
+	}
+	state := rand.Text()
+	http.SetCookie(w, &http.Cookie{
+		Name: stateCookie, Value: state, Path: "/oauth/", MaxAge: 600,
+		HttpOnly: true, Secure: cfg.OAuth.secureCookies(), SameSite: http.SameSiteLaxMode,
+	})
+	q := url.Values{
+		"client_id":     {cfg.OAuth.ClientID},
+		"redirect_uri":  {cfg.OAuth.RedirectURL},
+		"response_type": {"code"},
+		"state":         {state},
+		"scope":         {"api"},
+	}
+	http.Redirect(w, r, cfg.Hosts[0].Base+"/oauth/authorize?"+q.Encode(), http.StatusFound)
+}
+
+func oauthCallbackHandler(w http.ResponseWriter, r *http.Request) {
+	cfg, err := loadConfig()
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	if cfg.OAuth == nil {
+		http.NotFound(w, r)
+		return
+	}
+	c, err := r.Cookie(stateCookie)
+	if err != nil || c.Value == "" || c.Value != r.URL.Query().Get("state") {
+		http.Error(w, "invalid OAuth state", http.StatusBadRequest)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{Name: stateCookie, Path: "/oauth/", MaxAge: -1})
+
+	h := cfg.Hosts[0]
+	tok, err := requestToken(r.Context(), h.Base, url.Values{
+		"client_id":     {cfg.OAuth.ClientID},
+		"client_secret": {cfg.OAuth.ClientSecret},
+		"code":          {r.URL.Query().Get("code")},
+		"grant_type":    {"authorization_code"},
+		"redirect_uri":  {cfg.OAuth.RedirectURL},
+	})
+	if err != nil {
+		slog.Warn("oauth login failed", "err", errorHint(err))
+		http.Error(w, "GitLab login failed", http.StatusBadGateway)
+		return
+	}
+	var me struct {
+		Username string `json:"username"`
+	}
+	if err := apiGet(r.Context(), h.Base+"/api/v4/user", "Bearer "+tok.AccessToken, &me); err != nil {
+		slog.Warn("oauth login failed", "err", errorHint(err))
+		http.Error(w, "GitLab login failed", http.StatusBadGateway)
+		return
+	}
+	s := session{Token: tok.AccessToken, Username: me.Username, Expires: tok.expires()}
+	if tok.RefreshToken != "" {
+		s.Refresh, s.Until = tok.RefreshToken, time.Now().Add(sessionLifetime)
+	}
+	http.SetCookie(w, cfg.OAuth.sessionCookie(s))
+	http.Redirect(w, r, "/", http.StatusFound)
+}
+
+type oauthToken struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int    `json:"expires_in"`
+}
+
+func (t oauthToken) expires() time.Time {
+	if t.ExpiresIn == 0 {
+		return time.Now().Add(2 * time.Hour)
+	}
+	return time.Now().Add(time.Duration(t.ExpiresIn) * time.Second)
+}
+
+// requestToken posts form to GitLab's token endpoint: a code for the
+// login, a refresh token later on.
+func requestToken(ctx context.Context, base string, form url.Values) (oauthToken, error) {
+	var tok oauthToken
+	u := base + "/oauth/token"
+	req, _ := http.NewRequestWithContext(ctx, "POST", u, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return tok, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return tok, newAPIError(u, resp)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tok); err != nil {
+		return tok, err
+	}
+	if tok.AccessToken == "" {
+		return tok, fmt.Errorf("GitLab returned no access token")
+	}
+	return tok, nil
+}
+
+func oauthLogoutHandler(w http.ResponseWriter, r *http.Request) {
+	http.SetCookie(w, &http.Cookie{Name: sessionCookie, Path: "/", MaxAge: -1})
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
@@ -0,0 +1,279 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Upper bound on concurrent GitLab requests within one fan-out; set once
+// from MAX_CONCURRENCY at startup.
+var fetchWorkers = 8
+
+func configureConcurrency() error {
+	n, err := envInt("MAX_CONCURRENCY", fetchWorkers)
+	if err != nil {
+		return err
+	}
+	if n < 1 {
+		return fmt.Errorf("MAX_CONCURRENCY must be a positive integer, got %d", n)
+	}
+	fetchWorkers = n
+	return nil
+}
+
+type pipelineKey struct {
+	Base      string
+	ProjectID int
+	IID       int
+}
+
+type pipelineEntry struct {
+	pipeline *Pipeline // nil: the MR has no pipelines
+	expires  time.Time
+}
+
+// pipelineCache remembers per-MR pipeline lookups, including misses.
+// Running and pending pipelines expire after activeTTL so their transition
+// to success or failed shows up sooner; everything else lives for ttl.
+type pipelineCache struct {
+	mu        sync.Mutex
+	ttl       time.Duration
+	activeTTL time.Duration
+	entries   map[pipelineKey]pipelineEntry
+}
+
+var pipelines = &pipelineCache{
+	ttl:       time.Minute,
+	activeTTL: 15 * time.Second,
+	entries:   map[pipelineKey]pipelineEntry{},
+}
+
+// configurePipelineCache reads PIPELINE_CACHE_SECONDS and
+// PIPELINE_ACTIVE_CACHE_SECONDS. The cache is only consulted on page loads,
+// so a short TTL means fresher dots, never a polling loop.
+func configurePipelineCache() error {
+	ttl, err := envInt("PIPELINE_CACHE_SECONDS", int(pipelines.ttl/time.Second))
+	if err != nil {
+		return err
+	}
+	active, err := envInt("PIPELINE_ACTIVE_CACHE_SECONDS", int(pipelines.activeTTL/time.Second))
+	if err != nil {
+		return err
+	}
+	pipelines.mu.Lock()
+	defer pipelines.mu.Unlock()
+	pipelines.ttl = time.Duration(ttl) * time.Second
+	pipelines.activeTTL = time.Duration(active) * time.Second
+	return nil
+}
+
+func pipelineActive(status string) bool {
+	switch status {
+	case "running", "pending", "created", "preparing", "waiting_for_resource":
+		return true
+	}
+	return false
+}
+
+// Active reports whether the pipeline is still going.
+func (p *Pipeline) Active() bool { return pipelineActive(p.Status) }
+
+// Duration is how long the pipeline has been running, or ran for once it
+// finished. Zero when GitLab didn't send the timestamps.
+func (p *Pipeline) Duration() time.Duration {
+	start := p.StartedAt.Time
+	if start.IsZero() {
+		start = p.CreatedAt.Time
+	}
+	if start.IsZero() {
+		return 0
+	}
+	if p.Active() {
+		return time.Since(start)
+	}
+	end := p.FinishedAt.Time
+	if end.IsZero() {
+		end = p.UpdatedAt.Time
+	}
+	if end.Before(start) {
+		return 0
+	}
+	return end.Sub(start)
+}
+
+// Ago is the time since a finished pipeline last changed.
+func (p *Pipeline) Ago() time.Duration {
+	end := p.FinishedAt.Time
+	if end.IsZero() {
+		end = p.UpdatedAt.Time
+	}
+	if end.IsZero() {
+		return 0
+	}
+	return time.Since(end)
+}
+
+// shortDuration renders d with its two largest units, e.g. "4m12s", "1h5m"
+// or "3d2h".
+func shortDuration(d time.Duration) string {
+	d = d.Round(time.Second)
+	switch {
+	case d < time.Minute:
+		return fmt.Sprintf("%ds", int(d.Seconds()))
+	case d < time.Hour:
+		return fmt.Sprintf("%dm%ds", int(d.Minutes()), int(d.Seconds())%60)
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh%dm", int(d.Hours()), int(d.Minutes())%60)
+	}
+	return fmt.Sprintf("%dd%dh", int(d.Hours())/24, int(d.Hours())%24)
+}
+
+func (c *pipelineCache) get(k pipelineKey) (*Pipeline, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[k]
+	if !ok || time.Now().After(e.expires) {
+		clientCounters.PipelineMisses.Add(1)
+		return nil, false
+	}
+	clientCounters.PipelineHits.Add(1)
+	return e.pipeline, true
+}
+
+func (c *pipelineCache) put(k pipelineKey, p *Pipeline) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ttl := c.ttl
+	if p != nil && pipelineActive(p.Status) {
+		ttl = c.activeTTL
+	}
+	c.entries[k] = pipelineEntry{pipeline: p, expires: time.Now().Add(ttl)}
+}
+
+// invalidate drops the entry for an MR on every host, since a webhook
+// doesn't say which GITLAB_BASE it came from. It returns how many were
+// dropped.
+func (c *pipelineCache) invalidate(projectID, iid int) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n := 0
+	for k := range c.entries {
+		if k.ProjectID == projectID && k.IID == iid {
+			delete(c.entries, k)
+			n++
+		}
+	}
+	return n
+}
+
+func (c *pipelineCache) prune() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	for k, e := range c.entries {
+		if now.After(e.expires) {
+			delete(c.entries, k)
+		}
+	}
+}
+
+// Attach latest pipeline if head_pipeline missing. Lookups run on a small
+// worker pool and go through the pipeline cache.
+func attachPipelines(ctx context.Context, base, token string, mrs []MR) []MR {
+	pipelines.prune()
+	sem := make(chan struct{}, fetchWorkers)
+	var wg sync.WaitGroup
+	for i := range mrs {
+		if mrs[i].HeadPipeline != nil || mrs[i].PipelineKnown {
+			continue
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int) {
+			defer func() { <-sem; wg.Done() }()
+			p, ok := latestPipeline(ctx, base, token, mrs[i].ProjectID, mrs[i].IID)
+			if !ok {
+				mrs[i].PipelineUnknown = true
+				return
+			}
+			if p == nil {
+				return
+			}
+			// Each worker only writes its own element, and gets a copy
+			// so the MR never aliases the shared cache entry.
+			cp := *p
+			mrs[i].HeadPipeline = &cp
+		}(i)
+	}
+	wg.Wait()
+	return mrs
+}
+
+// ignorePipelines drops pipelines whose status is in statuses, so the MR
+// renders without a dot. It runs after attachPipelines; dropping earlier
+// would just trigger a lookup.
+func ignorePipelines(mrs []MR, statuses []string) []MR {
+	if len(statuses) == 0 {
+		return mrs
+	}
+	for i := range mrs {
+		if p := mrs[i].HeadPipeline; p != nil && slices.Contains(statuses, p.Status) {
+			mrs[i].HeadPipeline = nil
+		}
+	}
+	return mrs
+}
+
+const pipelineRetryDelay = 250 * time.Millisecond
+
+// retryable is true for network errors, rate limiting and 5xx responses;
+// other API errors won't go away by asking again.
+func retryable(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode == http.StatusTooManyRequests || apiErr.StatusCode >= 500
+	}
+	return true
+}
+
+// latestPipeline reports ok=false only when the lookup itself failed; a
+// nil pipeline with ok=true means the MR has none.
+func latestPipeline(ctx context.Context, base, token string, projectID, iid int) (*Pipeline, bool) {
+	k := pipelineKey{base, projectID, iid}
+	if p, ok := pipelines.get(k); ok {
+		return p, true
+	}
+	// Newest first, so the first page is all we need.
+	u := fmt.Sprintf("%s/api/v4/projects/%d/merge_requests/%d/pipelines?per_page=1", base, projectID, iid)
+	pipes, _, err := fetchPage[Pipeline](ctx, httpClient, u, token)
+	if err != nil && retryable(err) {
+		// httpClient retries within one request; this is one more go after
+		// its backoff gave up. Failures are not cached.
+		select {
+		case <-ctx.Done():
+			return nil, false
+		case <-time.After(pipelineRetryDelay):
+		}
+		pipes, _, err = fetchPage[Pipeline](ctx, httpClient, u, token)
+	}
+	if err != nil {
+		return nil, false
+	}
+	var p *Pipeline
+	if len(pipes) > 0 {
+		p = &pipes[0]
+	}
+	pipelines.put(k, p)
+	return p, true
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPipelineTolerantTimes(t *testing.T) {
+	var p Pipeline
+	err := json.Unmarshal([]byte(`{"id":1,"status":"success","created_at":"2026-03-01T10:00:00.123Z","started_at":null,"updated_at":"2026-03-01 10:05:00 UTC","finished_at":""}`), &p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !p.StartedAt.IsZero() || !p.FinishedAt.IsZero() {
+		t.Errorf("null and empty should decode as zero: %+v", p)
+	}
+	if got := p.Duration(); got != 5*time.Minute-123*time.Millisecond {
+		t.Errorf("Duration() = %v", got)
+	}
+}
+
+// pipelineServer answers every MR pipelines lookup with one pipeline
+// whose ID is the MR's IID.
+func pipelineServer(tb testing.TB) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var project, iid int
+		if _, err := fmt.Sscanf(r.URL.Path, "/api/v4/projects/%d/merge_requests/%d/pipelines", &project, &iid); err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprintf(w, `[{"id":%d,"status":"success","web_url":"https://ci/%d"}]`, iid, iid)
+	}))
+	tb.Cleanup(srv.Close)
+	return srv
+}
+
+func resetPipelineCache() {
+	pipelines.mu.Lock()
+	pipelines.entries = map[pipelineKey]pipelineEntry{}
+	pipelines.mu.Unlock()
+}
+
+func teamMRs(n int) []MR {
+	mrs := make([]MR, n)
+	for i := range mrs {
+		mrs[i] = MR{ProjectID: 1, IID: i + 1}
+	}
+	return mrs
+}
+
+// BenchmarkAttachPipelines is a large team's sidebar: 200 MRs without a
+// head pipeline, looked up cold and from the cache.
+func BenchmarkAttachPipelines(b *testing.B) {
+	srv := pipelineServer(b)
+	ctx := context.Background()
+	b.Run("cold", func(b *testing.B) {
+		for b.Loop() {
+			resetPipelineCache()
+			attachPipelines(ctx, srv.URL, "", teamMRs(200))
+		}
+	})
+	b.Run("cached", func(b *testing.B) {
+		resetPipelineCache()
+		attachPipelines(ctx, srv.URL, "", teamMRs(200))
+		for b.Loop() {
+			attachPipelines(ctx, srv.URL, "", teamMRs(200))
+		}
+	})
+}
+
+// TestAttachPipelinesConcurrent runs page loads side by side over the same
+// MRs; with -race it catches writes through shared pipeline pointers.
+func TestAttachPipelinesConcurrent(t *testing.T) {
+	srv := pipelineServer(t)
+	resetPipelineCache()
+	ctx := context.Background()
+	results := make([][]MR, 8)
+	done := make(chan int)
+	for i := range results {
+		go func() {
+			results[i] = attachPipelines(ctx, srv.URL, "", teamMRs(50))
+			done <- i
+		}()
+	}
+	for range results {
+		<-done
+	}
+	for _, mrs := range results {
+		for _, m := range mrs {
+			if m.HeadPipeline == nil || m.HeadPipeline.ID != m.IID {
+				t.Fatalf("MR %d got pipeline %+v", m.IID, m.HeadPipeline)
+			}
+		}
+	}
+	// Changing one page's copy must not show on another's or in the cache.
+	results[0][0].HeadPipeline.Status = "failed"
+	if s := results[1][0].HeadPipeline.Status; s != "success" {
+		t.Errorf("another page's pipeline changed to %q", s)
+	}
+	if p, ok := pipelines.get(pipelineKey{srv.URL, 1, 1}); !ok || p.Status != "success" {
+		t.Errorf("cached pipeline changed: %+v", p)
+	}
+}
+
+func renderPipe(t *testing.T, m MR) string {
+	t.Helper()
+	var b strings.Builder
+	if err := page.ExecuteTemplate(&b, "pipe", m); err != nil {
+		t.Fatal(err)
+	}
+	return b.String()
+}
+
+func TestIgnoredPipelineRendersNoDot(t *testing.T) {
+	skipped := MR{ProjectID: 1, IID: 1, HeadPipeline: &Pipeline{Status: "skipped", WebURL: "https://ci/1"}}
+	if html := renderPipe(t, skipped); !strings.Contains(html, `data-status="skipped"`) {
+		t.Fatalf("without PIPELINE_IGNORE the skipped pipeline should show: %s", html)
+	}
+
+	mrs := ignorePipelines([]MR{skipped, {ProjectID: 1, IID: 2, HeadPipeline: &Pipeline{Status: "success"}}}, []string{"skipped", "manual"})
+	html := renderPipe(t, mrs[0])
+	if strings.Contains(html, `class="dot"`) || strings.Contains(html, `class="pipe"`) || strings.Contains(html, "https://ci/1") {
+		t.Errorf("ignored pipeline still rendered: %s", html)
+	}
+	if !strings.Contains(html, `class="pipe-slot"`) {
+		t.Errorf("the slot should stay for live updates: %s", html)
+	}
+	if html := renderPipe(t, mrs[1]); !strings.Contains(html, `data-status="success"`) {
+		t.Errorf("other pipelines should still render: %s", html)
+	}
+}
+
+func TestLatestPipelineRetriesAfterTransport(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Outlast the transport's own attempts, then recover.
+		if calls.Add(1) <= retryAttempts {
+			http.Error(w, "busy", http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprint(w, `[{"id":7,"status":"success"}]`)
+	}))
+	defer srv.Close()
+	resetPipelineCache()
+	old := httpClient
+	httpClient = newHTTPClient()
+	defer func() { httpClient = old }()
+
+	p, ok := latestPipeline(context.Background(), srv.URL, "", 1, 1)
+	if !ok || p == nil || p.ID != 7 {
+		t.Fatalf("got %+v, %v after %d calls", p, ok, calls.Load())
+	}
+}
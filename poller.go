@@ -0,0 +1,387 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+)
+
+// poller keeps the dashboard for the configured defaults collected in the
+// background, so page loads are served from memory instead of waiting on
+// GitLab. POLL_SECONDS turns it on; it stays off with the OAuth login,
+// where every visitor has their own token.
+type poller struct {
+	mu  sync.RWMutex
+	d   dashboard
+	err error
+	cfg config // what d was collected with
+	at  time.Time
+
+	kick     chan struct{}
+	interval time.Duration // 0 while off; set once before serving
+	watch    time.Duration // PIPELINE_WATCH_SECONDS; 0 is off
+	subs     map[chan struct{}]struct{}
+	watchers map[chan []mrChange]struct{}
+
+	done chan struct{} // closed by stop
+	wg   sync.WaitGroup
+}
+
+var polled = &poller{
+	kick:     make(chan struct{}, 1),
+	subs:     map[chan struct{}]struct{}{},
+	watchers: map[chan []mrChange]struct{}{},
+	done:     make(chan struct{}),
+}
+
+func startPoller(cfg config) {
+	secs, err := envInt("POLL_SECONDS", 0)
+	if err != nil {
+		fatal("config", "err", err)
+	}
+	if secs <= 0 {
+		return
+	}
+	if cfg.OAuth != nil {
+		slog.Warn("POLL_SECONDS is ignored with the OAuth login")
+		return
+	}
+	watch, err := envInt("PIPELINE_WATCH_SECONDS", 10)
+	if err != nil {
+		fatal("config", "err", err)
+	}
+	interval := time.Duration(secs) * time.Second
+	slog.Info("polling GitLab", "every", interval)
+	polled.interval = interval
+	if watch > 0 && watch < secs {
+		polled.watch = time.Duration(watch) * time.Second
+	}
+	polled.wg.Add(1)
+	go polled.run(interval)
+}
+
+func (p *poller) run(interval time.Duration) {
+	defer p.wg.Done()
+	if p.watch > 0 {
+		p.wg.Add(1)
+		go func() {
+			defer p.wg.Done()
+			t := time.NewTicker(p.watch)
+			defer t.Stop()
+			for {
+				select {
+				case <-t.C:
+					p.watchPipelines(p.watch)
+				case <-p.done:
+					return
+				}
+			}
+		}()
+	}
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		p.poll(max(interval, 30*time.Second))
+		select {
+		case <-t.C:
+		case <-p.kick:
+		case <-p.done:
+			return
+		}
+	}
+}
+
+// stop lets a poll in progress finish and ends the poller. Call it once.
+func (p *poller) stop() {
+	close(p.done)
+	p.wg.Wait()
+}
+
+func (p *poller) poll(timeout time.Duration) {
+	cfg, err := loadConfig()
+	if err != nil {
+		slog.Error("poll: config", "err", err)
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	d, err := collectDashboard(ctx, cfg)
+	if err != nil {
+		slog.Warn("poll incomplete", "err", errorHint(err))
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var changes []mrChange
+	if !p.at.IsZero() {
+		changes = diffPipelines(p.d, d, err == nil)
+	}
+	p.d, p.err, p.cfg, p.at = d, err, cfg, time.Now()
+	p.notify(changes)
+}
+
+// notify wakes the subscribers, and hands changes to the watchers. The
+// caller holds p.mu.
+func (p *poller) notify(changes []mrChange) {
+	for ch := range p.subs {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+	if len(changes) == 0 {
+		return
+	}
+	for ch := range p.watchers {
+		select {
+		case ch <- changes:
+		default: // a stuck client catches up on the next /events update
+		}
+	}
+}
+
+// watchPipelines re-reads the pipelines the snapshot has as running, so
+// one that finishes shows within seconds instead of at the next poll.
+func (p *poller) watchPipelines(timeout time.Duration) {
+	p.mu.RLock()
+	cfg := p.cfg
+	var active []MR
+	seen := map[string]bool{}
+	for _, m := range dashboardMRs(p.d) {
+		if m.Provider == "" && m.HeadPipeline != nil && m.HeadPipeline.Active() && !seen[m.Key()] {
+			seen[m.Key()] = true
+			active = append(active, m)
+		}
+	}
+	p.mu.RUnlock()
+	if len(active) == 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	updated := map[string]*Pipeline{}
+	for _, m := range active {
+		i := slices.IndexFunc(cfg.Hosts, func(h gitlabHost) bool { return h.Name == m.Host })
+		if i < 0 {
+			continue
+		}
+		h := cfg.Hosts[i]
+		var pl Pipeline
+		u := fmt.Sprintf("%s/api/v4/projects/%d/pipelines/%d", h.Base, m.ProjectID, m.HeadPipeline.ID)
+		if err := apiGet(ctx, u, h.Token, &pl); err != nil {
+			slog.Warn("pipeline watch failed", "mr", m.Key(), "err", errorHint(err))
+			continue
+		}
+		if pl.Status != m.HeadPipeline.Status {
+			updated[m.Key()] = &pl
+		}
+	}
+	if len(updated) == 0 {
+		return
+	}
+	p.patch(func(m MR) bool { return updated[m.Key()] != nil }, func(m *MR) {
+		m.HeadPipeline = updated[m.Key()]
+	})
+}
+
+// applyPipeline puts a pipeline a webhook reported on the MR in the
+// snapshot. A hook doesn't say which host it came from, so it applies to
+// the MR on every GitLab host.
+func (p *poller) applyPipeline(projectID, iid int, pl Pipeline) {
+	p.patch(func(m MR) bool {
+		return m.Provider == "" && m.ProjectID == projectID && m.IID == iid
+	}, func(m *MR) {
+		cp := pl
+		if old := m.HeadPipeline; old != nil && old.ID == pl.ID {
+			// Keep the timestamps; the hook has them in another format.
+			cp = *old
+			cp.Status = pl.Status
+		}
+		if cp.WebURL == "" && m.HeadPipeline != nil {
+			cp.WebURL = m.HeadPipeline.WebURL
+		}
+		m.HeadPipeline = &cp
+	})
+}
+
+// patch applies fn to the snapshot's MRs that match and tells the watchers.
+// fn must replace HeadPipeline rather than write through it: copies
+// handed out by get still point at the old pipelines.
+func (p *poller) patch(match func(MR) bool, fn func(*MR)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	ignore := p.cfg.PipelineIgnore
+	changed := map[string]MR{}
+	for _, list := range [][]MR{p.d.MRs, p.d.TeamMRs, p.d.Approved} {
+		for i := range list {
+			if !match(list[i]) {
+				continue
+			}
+			before := pipelineState(list[i])
+			fn(&list[i])
+			if pl := list[i].HeadPipeline; pl != nil && slices.Contains(ignore, pl.Status) {
+				list[i].HeadPipeline = nil
+			}
+			if pipelineState(list[i]) != before {
+				changed[list[i].Key()] = list[i]
+			}
+		}
+	}
+	if len(changed) == 0 {
+		return
+	}
+	changes := make([]mrChange, 0, len(changed))
+	for _, m := range changed {
+		changes = append(changes, mrChange{MR: m})
+	}
+	p.notify(changes)
+}
+
+// dropMR takes a merged or closed MR off the snapshot.
+func (p *poller) dropMR(projectID, iid int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var changes []mrChange
+	drop := func(m MR) bool {
+		if m.Provider != "" || m.ProjectID != projectID || m.IID != iid {
+			return false
+		}
+		if !slices.ContainsFunc(changes, func(c mrChange) bool { return c.MR.Key() == m.Key() }) {
+			changes = append(changes, mrChange{MR: m, Removed: true})
+		}
+		return true
+	}
+	p.d.MRs = slices.DeleteFunc(p.d.MRs, drop)
+	p.d.TeamMRs = slices.DeleteFunc(p.d.TeamMRs, drop)
+	p.d.Approved = slices.DeleteFunc(p.d.Approved, drop)
+	if len(changes) > 0 {
+		p.notify(changes)
+	}
+}
+
+func (p *poller) running() bool { return p.interval > 0 }
+
+// mrChange is an MR whose pipeline moved on, or that left the dashboard.
+type mrChange struct {
+	MR      MR
+	Removed bool
+}
+
+// dashboardMRs is every MR on d; one can be in more than one list.
+func dashboardMRs(d dashboard) []MR {
+	return slices.Concat(d.MRs, d.TeamMRs, d.Approved)
+}
+
+func pipelineState(m MR) string {
+	if m.HeadPipeline == nil {
+		return ""
+	}
+	return fmt.Sprintf("%d:%s", m.HeadPipeline.ID, m.HeadPipeline.Status)
+}
+
+// diffPipelines lists the MRs whose pipeline changed between two
+// snapshots. New MRs aren't changes; they arrive with the sections over
+// /events. Removals only count when next is complete, since a list that
+// failed to load would otherwise look emptied.
+func diffPipelines(prev, next dashboard, complete bool) []mrChange {
+	before := map[string]MR{}
+	for _, m := range dashboardMRs(prev) {
+		before[m.Key()] = m
+	}
+	var out []mrChange
+	for _, m := range dashboardMRs(next) {
+		old, ok := before[m.Key()]
+		if !ok {
+			continue
+		}
+		delete(before, m.Key())
+		if pipelineState(old) != pipelineState(m) {
+			out = append(out, mrChange{MR: m})
+		}
+	}
+	if complete {
+		for _, m := range before {
+			out = append(out, mrChange{MR: m, Removed: true})
+		}
+	}
+	return out
+}
+
+// watchChanges is subscribe for mrChanges, for /ws.
+func (p *poller) watchChanges() (<-chan []mrChange, func()) {
+	ch := make(chan []mrChange, 16)
+	p.mu.Lock()
+	p.watchers[ch] = struct{}{}
+	p.mu.Unlock()
+	return ch, func() {
+		p.mu.Lock()
+		delete(p.watchers, ch)
+		p.mu.Unlock()
+	}
+}
+
+// subscribe returns a channel that receives after every poll, and the
+// func to stop receiving. A slow subscriber misses nothing but repeats:
+// the channel holds one pending poll.
+func (p *poller) subscribe() (<-chan struct{}, func()) {
+	ch := make(chan struct{}, 1)
+	p.mu.Lock()
+	p.subs[ch] = struct{}{}
+	p.mu.Unlock()
+	return ch, func() {
+		p.mu.Lock()
+		delete(p.subs, ch)
+		p.mu.Unlock()
+	}
+}
+
+// refresh asks for a poll soon, e.g. after a todo was marked done. It
+// never blocks.
+func (p *poller) refresh() {
+	select {
+	case p.kick <- struct{}{}:
+	default:
+	}
+}
+
+// get returns the last snapshot if it answers cfg: same team and
+// milestone. ok is false before the first poll, or when the poller is off.
+func (p *poller) get(cfg config) (d dashboard, ok bool, err error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.at.IsZero() || cfg.Team != p.cfg.Team || cfg.Milestone != p.cfg.Milestone {
+		return d, false, nil
+	}
+	d = p.d
+	// The page's filters build new slices, but nothing may write
+	// through to the shared snapshot.
+	d.MRs, d.TeamMRs, d.Approved = slices.Clone(d.MRs), slices.Clone(d.TeamMRs), slices.Clone(d.Approved)
+	d.Issues, d.Todos = slices.Clone(d.Issues), slices.Clone(d.Todos)
+	d.PolledAt = p.at
+	return d, true, p.err
+}
+
+// snapshot is the last poll, whatever it was collected for.
+func (p *poller) snapshot() (config, dashboard, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	d := p.d
+	d.MRs, d.TeamMRs, d.Approved = slices.Clone(d.MRs), slices.Clone(d.TeamMRs), slices.Clone(d.Approved)
+	d.Issues, d.Todos = slices.Clone(d.Issues), slices.Clone(d.Todos)
+	return p.cfg, d, p.err
+}
+
+// collectOrPolled serves sections from the poller's snapshot when it can
+// and collects them live otherwise. With the OAuth login there is no
+// poller; each user's dashboards are cached for a short while instead.
+func collectOrPolled(ctx context.Context, cfg config, sections []string) (dashboard, error) {
+	if d, ok, err := polled.get(cfg); ok {
+		return d, err
+	}
+	if cfg.OAuth != nil {
+		return userCache.collect(ctx, cfg, sections)
+	}
+	return collectSections(ctx, cfg, sections)
+}
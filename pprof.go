@@ -0,0 +1,32 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/pprof"
+	"os"
+)
+
+// startPprof serves net/http/pprof on PPROF_ADDR (default localhost:6060)
+// when DEBUG_PPROF is set, for chasing memory or goroutine growth in a
+// long-running instance. It is a separate listener without auth; keep it
+// on localhost or a private network.
+func startPprof() {
+	if !envBool("DEBUG_PPROF") {
+		return
+	}
+	addr := os.Getenv("PPROF_ADDR")
+	if addr == "" {
+		addr = "localhost:6060"
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	go func() {
+		slog.Info("pprof listening", "url", "http://"+addr+"/debug/pprof/")
+		slog.Error("pprof stopped", "err", http.ListenAndServe(addr, mux))
+	}()
+}
@@ -0,0 +1,81 @@
+package main
+
+import "context"
+
+// Provider is one forge instance the dashboard reads from. Scopes and
+// sections a forge doesn't have return nil, nil.
+type Provider interface {
+	Name() string
+	// ListMRs lists the MRs for scope "mine", "team" or "approved",
+	// without pipelines.
+	ListMRs(ctx context.Context, cfg config, scope string) ([]MR, error)
+	// ListPipelines fills in the pipeline of each MR it can.
+	ListPipelines(ctx context.Context, cfg config, mrs []MR) []MR
+	ListIssues(ctx context.Context, cfg config) ([]Issue, error)
+	ListTodos(ctx context.Context, cfg config) ([]Todo, error)
+}
+
+// providers lists every configured forge, GitLab hosts first.
+func (c config) providers() []Provider {
+	var ps []Provider
+	for _, h := range c.Hosts {
+		ps = append(ps, gitlabProvider{h})
+	}
+	if c.GitHub != nil {
+		ps = append(ps, githubProvider{*c.GitHub})
+	}
+	return ps
+}
+
+type gitlabProvider struct{ host gitlabHost }
+
+func (p gitlabProvider) Name() string { return p.host.Name }
+
+func (p gitlabProvider) ListMRs(ctx context.Context, cfg config, scope string) ([]MR, error) {
+	switch scope {
+	case "mine":
+		return collectMine(ctx, cfg, p.host)
+	case "team":
+		return collectTeam(ctx, cfg, p.host)
+	case "approved":
+		return collectApproved(ctx, cfg, p.host)
+	}
+	return nil, nil
+}
+
+func (p gitlabProvider) ListPipelines(ctx context.Context, cfg config, mrs []MR) []MR {
+	return ignorePipelines(attachPipelines(ctx, p.host.Base, p.host.Token, mrs), cfg.PipelineIgnore)
+}
+
+func (p gitlabProvider) ListIssues(ctx context.Context, cfg config) ([]Issue, error) {
+	return collectIssues(ctx, cfg, p.host)
+}
+
+func (p gitlabProvider) ListTodos(ctx context.Context, cfg config) ([]Todo, error) {
+	return collectTodos(ctx, p.host)
+}
+
+// githubProvider only has pull requests for "mine"; GitHub pipelines,
+// issues and notifications aren't shown.
+type githubProvider struct{ gh githubConfig }
+
+func (p githubProvider) Name() string { return p.gh.Name }
+
+func (p githubProvider) ListMRs(ctx context.Context, cfg config, scope string) ([]MR, error) {
+	if scope != "mine" {
+		return nil, nil
+	}
+	return collectGitHub(ctx, cfg, p.gh)
+}
+
+func (p githubProvider) ListPipelines(ctx context.Context, cfg config, mrs []MR) []MR {
+	return mrs
+}
+
+func (p githubProvider) ListIssues(ctx context.Context, cfg config) ([]Issue, error) {
+	return nil, nil
+}
+
+func (p githubProvider) ListTodos(ctx context.Context, cfg config) ([]Todo, error) {
+	return nil, nil
+}
@@ -0,0 +1,450 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Web Push (RFC 8030) lets a backgrounded dashboard tab show notifications.
+// The server signs its pushes with a VAPID key (RFC 8292) it generates on
+// first use; it and the browsers' subscriptions live in PUSH_FILE. The
+// private key is sealed with TOKEN_KEY when that is set. Payloads are
+// encrypted for the browser as RFC 8291 describes.
+
+type pushSubscription struct {
+	Endpoint string `json:"endpoint"`
+	Keys     struct {
+		P256dh string `json:"p256dh"`
+		Auth   string `json:"auth"`
+	} `json:"keys"`
+}
+
+type pushFile struct {
+	PublicKey     string                        `json:"public_key"`
+	PrivateKey    string                        `json:"private_key"`
+	Subscriptions map[string][]pushSubscription `json:"subscriptions"` // by user
+}
+
+type pushStore struct {
+	mu   sync.Mutex
+	path string
+	on   bool // WEB_PUSH
+	key  *ecdsa.PrivateKey
+	pub  string // base64url, for the browser's applicationServerKey
+}
+
+var pushes = &pushStore{}
+
+// maxPushSubscriptions caps each user's browsers; the oldest goes first.
+const maxPushSubscriptions = 10
+
+// pushServices are where subscriptions may point: PUSH_SERVICES, or the
+// push services of the major browsers. Each also matches its subdomains.
+var pushServices = []string{"fcm.googleapis.com", "push.services.mozilla.com", "push.apple.com", "notify.windows.com"}
+
+// pushClient delivers pushes. It always verifies TLS, follows no
+// redirects and has none of httpClient's GitLab transports.
+var pushClient = &http.Client{
+	Timeout:       10 * time.Second,
+	CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+}
+
+// pushEndpointAllowed is an https URL on one of the pushServices.
+func pushEndpointAllowed(endpoint string) bool {
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Scheme != "https" || u.User != nil || (u.Port() != "" && u.Port() != "443") {
+		return false
+	}
+	host := u.Hostname()
+	return slices.ContainsFunc(pushServices, func(s string) bool {
+		return host == s || strings.HasSuffix(host, "."+s)
+	})
+}
+
+// configurePush turns Web Push on with WEB_PUSH, which needs the poller to
+// see what is new. The poller only watches the env user, so it doesn't go
+// with the OAuth login.
+func configurePush() error {
+	if !envBool("WEB_PUSH") {
+		return nil
+	}
+	if os.Getenv("GITLAB_OAUTH_CLIENT_ID") != "" {
+		return errors.New("WEB_PUSH doesn't work with GitLab OAuth; notifications only follow GITLAB_USERNAME")
+	}
+	if !dashboardCredentialsSet() {
+		return errors.New("WEB_PUSH needs the dashboard behind DASHBOARD_USER and DASHBOARD_PASS or DASHBOARD_TOKEN")
+	}
+	if s := splitUsers(os.Getenv("PUSH_SERVICES")); s != nil {
+		pushServices = s
+	}
+	pushes.path = os.Getenv("PUSH_FILE")
+	if pushes.path == "" {
+		pushes.path = "push.json"
+	}
+	pushes.mu.Lock()
+	defer pushes.mu.Unlock()
+	f, err := pushes.load()
+	if err != nil {
+		return fmt.Errorf("%s: %w", pushes.path, err)
+	}
+	if f.PrivateKey == "" {
+		if err := pushes.generate(&f); err != nil {
+			return err
+		}
+		slog.Info("generated a VAPID key for Web Push", "file", pushes.path)
+	}
+	raw, err := openSecret(f.PrivateKey)
+	if err != nil {
+		return fmt.Errorf("%s: %w", pushes.path, err)
+	}
+	b, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return fmt.Errorf("%s: bad private key", pushes.path)
+	}
+	if pushes.key, err = ecdsa.ParseRawPrivateKey(elliptic.P256(), b); err != nil {
+		return fmt.Errorf("%s: %w", pushes.path, err)
+	}
+	pub, err := pushes.key.PublicKey.Bytes()
+	if err != nil {
+		return err
+	}
+	pushes.pub = base64.RawURLEncoding.EncodeToString(pub)
+	pushes.on = true
+	return nil
+}
+
+func (s *pushStore) generate(f *pushFile) error {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+	priv, err := key.Bytes()
+	if err != nil {
+		return err
+	}
+	pub, err := key.PublicKey.Bytes()
+	if err != nil {
+		return err
+	}
+	f.PublicKey = base64.RawURLEncoding.EncodeToString(pub)
+	f.PrivateKey = base64.RawURLEncoding.EncodeToString(priv)
+	tk, err := tokenKey()
+	if err != nil {
+		return err
+	}
+	if tk != nil {
+		f.PrivateKey = sealedPrefix + base64.StdEncoding.EncodeToString(seal(tk, []byte(f.PrivateKey)))
+	}
+	return s.save(f)
+}
+
+// load and save are called with s.mu held.
+func (s *pushStore) load() (pushFile, error) {
+	f := pushFile{Subscriptions: map[string][]pushSubscription{}}
+	b, err := os.ReadFile(s.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return f, nil
+	}
+	if err != nil {
+		return f, err
+	}
+	if err := json.Unmarshal(b, &f); err != nil {
+		return f, err
+	}
+	if f.Subscriptions == nil {
+		f.Subscriptions = map[string][]pushSubscription{}
+	}
+	return f, nil
+}
+
+func (s *pushStore) save(f *pushFile) error {
+	b, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.path, b)
+}
+
+// update applies fn to the user's subscriptions and saves them.
+func (s *pushStore) update(user string, fn func([]pushSubscription) []pushSubscription) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, err := s.load()
+	if err != nil {
+		return err
+	}
+	if subs := fn(f.Subscriptions[user]); len(subs) > 0 {
+		f.Subscriptions[user] = subs
+	} else {
+		delete(f.Subscriptions, user)
+	}
+	return s.save(&f)
+}
+
+func (s *pushStore) subscriptions(user string) ([]pushSubscription, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, err := s.load()
+	return f.Subscriptions[user], err
+}
+
+// errPushGone is a subscription the push service no longer knows.
+var errPushGone = errors.New("push subscription expired")
+
+// send encrypts payload for sub and posts it to the push service.
+func (s *pushStore) send(ctx context.Context, sub pushSubscription, payload []byte) error {
+	if !pushEndpointAllowed(sub.Endpoint) {
+		return errPushGone
+	}
+	body, err := encryptPush(sub, payload)
+	if err != nil {
+		return err
+	}
+	auth, err := s.vapid(sub.Endpoint)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", sub.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Encoding", "aes128gcm")
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("TTL", "86400")
+	req.Header.Set("Urgency", "normal")
+	req.Header.Set("Authorization", auth)
+	resp, err := pushClient.Do(req)
+	if err != nil {
+		var uerr *url.Error
+		if errors.As(err, &uerr) {
+			err = uerr.Err
+		}
+		return fmt.Errorf("POST %s: %w", webhookHost(sub.Endpoint), err)
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		return errPushGone
+	case resp.StatusCode >= 300:
+		return fmt.Errorf("POST %s -> %d %s", webhookHost(sub.Endpoint), resp.StatusCode, http.StatusText(resp.StatusCode))
+	}
+	return nil
+}
+
+// vapid is the Authorization header for the endpoint's push service: an
+// ES256 JWT for its origin, and our public key.
+func (s *pushStore) vapid(endpoint string) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", err
+	}
+	subject := os.Getenv("VAPID_SUBJECT")
+	if subject == "" {
+		subject = "mailto:homepager@localhost"
+	}
+	enc := base64.RawURLEncoding.EncodeToString
+	header := enc([]byte(`{"typ":"JWT","alg":"ES256"}`))
+	claims, _ := json.Marshal(map[string]any{
+		"aud": u.Scheme + "://" + u.Host,
+		"exp": time.Now().Add(12 * time.Hour).Unix(),
+		"sub": subject,
+	})
+	signing := header + "." + enc(claims)
+	sum := sha256.Sum256([]byte(signing))
+	r, sg, err := ecdsa.Sign(rand.Reader, s.key, sum[:])
+	if err != nil {
+		return "", err
+	}
+	sig := make([]byte, 64)
+	r.FillBytes(sig[:32])
+	sg.FillBytes(sig[32:])
+	return "vapid t=" + signing + "." + enc(sig) + ", k=" + s.pub, nil
+}
+
+// encryptPush is RFC 8291's aes128gcm content encoding, as one record.
+func encryptPush(sub pushSubscription, payload []byte) ([]byte, error) {
+	uaPub, err := base64.RawURLEncoding.DecodeString(trimPad(sub.Keys.P256dh))
+	if err != nil {
+		return nil, errors.New("bad p256dh key")
+	}
+	secret, err := base64.RawURLEncoding.DecodeString(trimPad(sub.Keys.Auth))
+	if err != nil {
+		return nil, errors.New("bad auth secret")
+	}
+	ua, err := ecdh.P256().NewPublicKey(uaPub)
+	if err != nil {
+		return nil, err
+	}
+	as, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	shared, err := as.ECDH(ua)
+	if err != nil {
+		return nil, err
+	}
+	asPub := as.PublicKey().Bytes()
+
+	prk, err := hkdf.Extract(sha256.New, shared, secret)
+	if err != nil {
+		return nil, err
+	}
+	ikm, err := hkdf.Expand(sha256.New, prk, "WebPush: info\x00"+string(uaPub)+string(asPub), 32)
+	if err != nil {
+		return nil, err
+	}
+	salt := make([]byte, 16)
+	rand.Read(salt)
+	prk, err = hkdf.Extract(sha256.New, ikm, salt)
+	if err != nil {
+		return nil, err
+	}
+	cek, err := hkdf.Expand(sha256.New, prk, "Content-Encoding: aes128gcm\x00", 16)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := hkdf.Expand(sha256.New, prk, "Content-Encoding: nonce\x00", 12)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(cek)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	// salt, record size, key id (our public key), then the one record
+	// with its last-record delimiter.
+	out := append(salt, 0, 0, 0, 0, byte(len(asPub)))
+	binary.BigEndian.PutUint32(out[16:20], 4096)
+	out = append(out, asPub...)
+	return gcm.Seal(out, nonce, append(slices.Clip(payload), 2), nil), nil
+}
+
+// Browsers hand out the keys in base64url, some with padding.
+func trimPad(s string) string {
+	for len(s) > 0 && s[len(s)-1] == '=' {
+		s = s[:len(s)-1]
+	}
+	return s
+}
+
+// pushSink delivers notifications to the browsers of the poller's user.
+type pushSink struct{}
+
+func (pushSink) Name() string { return "webpush" }
+
+func (pushSink) Send(ctx context.Context, e notifyEvent) error {
+	cfg, _, _ := polled.snapshot()
+	subs, err := pushes.subscriptions(cfg.User)
+	if err != nil || len(subs) == 0 {
+		return err
+	}
+	payload, _ := json.Marshal(map[string]string{
+		"title": e.headline(),
+		"body":  e.Title,
+		"url":   e.URL,
+		"tag":   e.Kind + ":" + e.URL,
+	})
+	var gone []string
+	var errs []error
+	for _, sub := range subs {
+		switch err := pushes.send(ctx, sub, payload); {
+		case errors.Is(err, errPushGone):
+			gone = append(gone, sub.Endpoint)
+		case err != nil:
+			errs = append(errs, err)
+		}
+	}
+	if len(gone) > 0 {
+		err := pushes.update(cfg.User, func(subs []pushSubscription) []pushSubscription {
+			return slices.DeleteFunc(subs, func(s pushSubscription) bool { return slices.Contains(gone, s.Endpoint) })
+		})
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// pushKeyHandler hands the browser the VAPID public key to subscribe with.
+func pushKeyHandler(w http.ResponseWriter, r *http.Request) {
+	if !pushes.on {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprint(w, pushes.pub)
+}
+
+// pushSubscribeHandler stores the subscription the browser posts as JSON;
+// DELETE removes it again.
+func pushSubscribeHandler(w http.ResponseWriter, r *http.Request) {
+	if !pushes.on {
+		http.NotFound(w, r)
+		return
+	}
+	cfg, err := requestConfig(r)
+	if err != nil {
+		writeConfigError(w, r, err)
+		return
+	}
+	var sub pushSubscription
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 8<<10)).Decode(&sub); err != nil {
+		http.Error(w, "invalid subscription", http.StatusBadRequest)
+		return
+	}
+	if !pushEndpointAllowed(sub.Endpoint) {
+		http.Error(w, "invalid subscription", http.StatusBadRequest)
+		return
+	}
+	err = pushes.update(cfg.User, func(subs []pushSubscription) []pushSubscription {
+		subs = slices.DeleteFunc(subs, func(s pushSubscription) bool { return s.Endpoint == sub.Endpoint })
+		if r.Method == "DELETE" {
+			return subs
+		}
+		subs = append(subs, sub)
+		return subs[max(len(subs)-maxPushSubscriptions, 0):]
+	})
+	if err != nil {
+		slog.Error("push subscriptions", "err", err)
+		http.Error(w, "could not save subscription", 500)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// serviceWorkerHandler serves the service worker from the root, so its
+// scope covers the whole dashboard.
+func serviceWorkerHandler(w http.ResponseWriter, r *http.Request) {
+	b, err := fs.ReadFile(assetFS(), "static/sw.js")
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/javascript; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Write(b)
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPushEndpointAllowed(t *testing.T) {
+	for _, tt := range []struct {
+		endpoint string
+		want     bool
+	}{
+		{"https://fcm.googleapis.com/fcm/send/abc", true},
+		{"https://updates.push.services.mozilla.com/wpush/v2/abc", true},
+		{"https://web.push.apple.com/abc", true},
+		{"https://wns2-am3p.notify.windows.com/w/?token=abc", true},
+		{"https://fcm.googleapis.com:443/fcm/send/abc", true},
+		{"http://fcm.googleapis.com/fcm/send/abc", false},
+		{"https://fcm.googleapis.com:8443/fcm/send/abc", false},
+		{"https://user@fcm.googleapis.com/fcm/send/abc", false},
+		{"https://evilfcm.googleapis.com.example.com/abc", false},
+		{"https://notfcm.googleapis.com.evil/abc", false},
+		{"https://169.254.169.254/latest/meta-data", false},
+		{"https://localhost/abc", false},
+		{"not a url", false},
+	} {
+		if got := pushEndpointAllowed(tt.endpoint); got != tt.want {
+			t.Errorf("pushEndpointAllowed(%q) = %v, want %v", tt.endpoint, got, tt.want)
+		}
+	}
+}
+
+func TestConfigurePushRejectsOAuth(t *testing.T) {
+	t.Setenv("WEB_PUSH", "true")
+	t.Setenv("DASHBOARD_TOKEN", "s3cret")
+	t.Setenv("GITLAB_OAUTH_CLIENT_ID", "app")
+	if err := configurePush(); err == nil || !strings.Contains(err.Error(), "OAuth") {
+		t.Errorf("got %v, want WEB_PUSH refused with OAuth", err)
+	}
+}
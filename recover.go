@@ -0,0 +1,33 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+)
+
+// serverHandler wraps root in what every response goes through. gzip sits
+// outside recoverPanics so the 500 goes through the gzipWriter instead of
+// the writer flushing a half-done 200 body on the way out.
+func serverHandler(root http.Handler) http.Handler {
+	return gzipResponses(recoverPanics(root))
+}
+
+// recoverPanics turns a panic in next into a logged stack trace and a 500,
+// so one bad request can't take the connection down with it.
+func recoverPanics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			err := recover()
+			if err == nil {
+				return
+			}
+			if err == http.ErrAbortHandler {
+				panic(err) // the server's own way to abort a response
+			}
+			slog.Error("panic", "method", r.Method, "path", r.URL.Path, "err", err, "stack", string(debug.Stack()))
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
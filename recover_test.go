@@ -0,0 +1,76 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRecoverPanics(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/boom", func(w http.ResponseWriter, r *http.Request) {
+		var m map[string]int
+		m["x"] = 1 // nil map write
+	})
+	// gzip still holds this back, so the 500 can replace it.
+	mux.HandleFunc("/half", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "half a page")
+		panic("boom")
+	})
+	mux.HandleFunc("/ok", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "fine")
+	})
+	srv := httptest.NewServer(serverHandler(mux))
+	defer srv.Close()
+
+	for _, enc := range []string{"identity", "gzip"} {
+		get := func(path string) (*http.Response, error) {
+			req, _ := http.NewRequest("GET", srv.URL+path, nil)
+			req.Header.Set("Accept-Encoding", enc)
+			return http.DefaultClient.Do(req)
+		}
+		resp, err := get("/boom")
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusInternalServerError {
+			t.Errorf("%s: panicking handler: status %d, want 500", enc, resp.StatusCode)
+		}
+		if enc == "gzip" {
+			if resp, err = get("/half"); err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusInternalServerError {
+				t.Errorf("panic after a partial gzip body: status %d, want 500", resp.StatusCode)
+			}
+		}
+
+		for range 3 {
+			resp, err = get("/ok")
+			if err != nil {
+				t.Fatalf("%s: server stopped serving after a panic: %v", enc, err)
+			}
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusOK || string(body) != "fine" {
+				t.Errorf("%s: after a panic: %d %q", enc, resp.StatusCode, body)
+			}
+		}
+	}
+}
+
+func TestRecoverPanicsAbortHandler(t *testing.T) {
+	h := recoverPanics(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	}))
+	defer func() {
+		if err := recover(); err != http.ErrAbortHandler {
+			t.Errorf("recovered %v, want http.ErrAbortHandler passed on", err)
+		}
+	}()
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	t.Error("ErrAbortHandler was swallowed")
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	retryAttempts = 3
+	retryBase     = 200 * time.Millisecond
+	// Longer waits than this aren't worth holding a page load for; the
+	// error goes through instead.
+	maxRetryWait = 5 * time.Second
+)
+
+// clientCounters are reported under "client" in /stats.json.
+var clientCounters struct {
+	Retries     atomic.Int64 // requests sent again
+	RateLimited atomic.Int64 // 429 responses
+	Paused      atomic.Int64 // requests held back by RateLimit-Remaining: 0
+	NotModified atomic.Int64 // 304s answered from the ETag cache
+
+	PipelineHits   atomic.Int64 // pipeline lookups answered from the cache
+	PipelineMisses atomic.Int64
+}
+
+type clientStats struct {
+	Retries     int64 `json:"retries"`
+	RateLimited int64 `json:"rate_limited"`
+	Paused      int64 `json:"paused"`
+	NotModified int64 `json:"not_modified"`
+
+	PipelineHits   int64 `json:"pipeline_cache_hits"`
+	PipelineMisses int64 `json:"pipeline_cache_misses"`
+}
+
+func currentClientStats() clientStats {
+	return clientStats{
+		Retries:     clientCounters.Retries.Load(),
+		RateLimited: clientCounters.RateLimited.Load(),
+		Paused:      clientCounters.Paused.Load(),
+		NotModified: clientCounters.NotModified.Load(),
+
+		PipelineHits:   clientCounters.PipelineHits.Load(),
+		PipelineMisses: clientCounters.PipelineMisses.Load(),
+	}
+}
+
+// retryTransport retries GETs that failed for a transient reason: network
+// errors, 429 and 5xx. It waits for Retry-After when the server sends one,
+// else backs off exponentially with jitter. Once a host reports
+// RateLimit-Remaining: 0, later requests to it wait for RateLimit-Reset.
+type retryTransport struct {
+	next http.RoundTripper
+
+	mu      sync.Mutex
+	resumes map[string]time.Time // host -> RateLimit-Reset
+}
+
+func newRetryTransport(next http.RoundTripper) *retryTransport {
+	return &retryTransport{next: next, resumes: map[string]time.Time{}}
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	if err := t.waitForReset(ctx, req.URL.Host); err != nil {
+		return nil, err
+	}
+	for attempt := 1; ; attempt++ {
+		resp, err := t.next.RoundTrip(req)
+		t.noteRateLimit(req.URL.Host, resp)
+		if attempt == retryAttempts || (req.Method != "GET" && req.Method != "HEAD") {
+			return resp, err
+		}
+		wait, ok := retryDelay(ctx, attempt, resp, err)
+		if !ok {
+			return resp, err
+		}
+		if resp != nil {
+			_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4<<10))
+			resp.Body.Close()
+		}
+		clientCounters.Retries.Add(1)
+		if err := sleepCtx(ctx, wait); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// retryDelay reports whether to try again, and after how long.
+func retryDelay(ctx context.Context, attempt int, resp *http.Response, err error) (time.Duration, bool) {
+	if ctx.Err() != nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return 0, false
+	}
+	if err == nil {
+		if resp.StatusCode == http.StatusTooManyRequests {
+			clientCounters.RateLimited.Add(1)
+		}
+		if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode < 500 {
+			return 0, false
+		}
+		if d, ok := retryAfter(resp.Header.Get("Retry-After")); ok {
+			return d, d <= maxRetryWait
+		}
+	}
+	backoff := retryBase << (attempt - 1)
+	return backoff + rand.N(backoff), true
+}
+
+// retryAfter parses Retry-After, either seconds or an HTTP date.
+func retryAfter(v string) (time.Duration, bool) {
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		return time.Duration(max(secs, 0)) * time.Second, true
+	}
+	if at, err := http.ParseTime(v); err == nil {
+		return max(time.Until(at), 0), true
+	}
+	return 0, false
+}
+
+func (t *retryTransport) noteRateLimit(host string, resp *http.Response) {
+	if resp == nil || resp.Header.Get("RateLimit-Remaining") != "0" {
+		return
+	}
+	reset, err := strconv.ParseInt(resp.Header.Get("RateLimit-Reset"), 10, 64)
+	if err != nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.resumes[host] = time.Unix(reset, 0)
+}
+
+// waitForReset holds a request back while host's rate limit is used up,
+// unless that would take longer than maxRetryWait.
+func (t *retryTransport) waitForReset(ctx context.Context, host string) error {
+	t.mu.Lock()
+	wait := time.Until(t.resumes[host])
+	t.mu.Unlock()
+	if wait <= 0 || wait > maxRetryWait {
+		return nil
+	}
+	clientCounters.Paused.Add(1)
+	return sleepCtx(ctx, wait)
+}
+
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+type reviewer struct {
+	User struct {
+		Username string `json:"username"`
+	} `json:"user"`
+	State string `json:"state"`
+}
+
+// attachReviewStates sets ReviewState to user's state on each MR, e.g.
+// "unreviewed", "reviewed", "requested_changes" or "approved".
+func attachReviewStates(ctx context.Context, base, token, user string, mrs []MR) []MR {
+	sem := make(chan struct{}, fetchWorkers)
+	var wg sync.WaitGroup
+	for i := range mrs {
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int) {
+			defer func() { <-sem; wg.Done() }()
+			var reviewers []reviewer
+			u := fmt.Sprintf("%s/api/v4/projects/%d/merge_requests/%d/reviewers", base, mrs[i].ProjectID, mrs[i].IID)
+			if err := apiGet(ctx, u, token, &reviewers); err != nil {
+				return
+			}
+			for _, r := range reviewers {
+				if r.User.Username == user {
+					mrs[i].ReviewState = r.State
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	return mrs
+}
+
+// awaitingFirstLook reports whether I'm a reviewer who hasn't engaged yet.
+func awaitingFirstLook(m MR) bool {
+	return m.ReviewState == "unreviewed"
+}
+
+type mrGroup struct {
+	Title string // message key, see catalogs
+	MRs   []MR
+}
+
+// ReviewGroups splits my MRs into those awaiting my first look and the
+// rest, dropping empty groups.
+func (d dashboard) ReviewGroups() []mrGroup {
+	var first, rest []MR
+	for _, m := range d.MRs {
+		if awaitingFirstLook(m) {
+			first = append(first, m)
+		} else {
+			rest = append(rest, m)
+		}
+	}
+	var groups []mrGroup
+	if len(first) > 0 {
+		groups = append(groups, mrGroup{"group.first_look", first})
+	}
+	if len(rest) > 0 {
+		groups = append(groups, mrGroup{"group.in_progress", rest})
+	}
+	return groups
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestReviewGroups(t *testing.T) {
+	for _, tt := range []struct {
+		state string
+		want  string // group key
+	}{
+		{"unreviewed", "group.first_look"},
+		{"reviewed", "group.in_progress"},
+		{"requested_changes", "group.in_progress"},
+		{"approved", "group.in_progress"},
+		{"review_started", "group.in_progress"}, // unknown states fall back
+		{"", "group.in_progress"},               // not a reviewer, e.g. the assignee
+	} {
+		d := dashboard{MRs: []MR{{IID: 1, ReviewState: tt.state}}}
+		groups := d.ReviewGroups()
+		if len(groups) != 1 || groups[0].Title != tt.want || len(groups[0].MRs) != 1 {
+			t.Errorf("state %q: got %+v, want one MR in %s", tt.state, groups, tt.want)
+		}
+	}
+	if groups := (dashboard{}).ReviewGroups(); len(groups) != 0 {
+		t.Errorf("no MRs should give no groups, got %+v", groups)
+	}
+}
+
+func TestAttachReviewStates(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v4/projects/1/merge_requests/1/reviewers":
+			w.Write([]byte(`[{"user":{"username":"someone"},"state":"approved"},{"user":{"username":"me"},"state":"unreviewed"}]`))
+		case "/api/v4/projects/1/merge_requests/2/reviewers":
+			w.Write([]byte(`[{"user":{"username":"me"},"state":"requested_changes"}]`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	mrs := attachReviewStates(context.Background(), srv.URL, "", "me", []MR{{ProjectID: 1, IID: 1}, {ProjectID: 1, IID: 2}, {ProjectID: 1, IID: 3}})
+	for i, want := range []string{"unreviewed", "requested_changes", ""} {
+		if mrs[i].ReviewState != want {
+			t.Errorf("MR %d: ReviewState = %q, want %q", mrs[i].IID, mrs[i].ReviewState, want)
+		}
+	}
+}
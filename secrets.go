@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/nacl/secretbox"
+)
+
+// Tokens in the environment or config.yaml may be stored encrypted, as
+// "enc:" plus a NaCl secretbox sealed with TOKEN_KEY, so the files on disk
+// are no use without the key. TOKEN_KEY is 32 random bytes in base64, e.g.
+// from `openssl rand -base64 32`; -encrypt-token seals a token with it.
+const sealedPrefix = "enc:"
+
+func tokenKey() (*[32]byte, error) {
+	v := os.Getenv("TOKEN_KEY")
+	if v == "" {
+		return nil, nil
+	}
+	b, err := base64.StdEncoding.DecodeString(v)
+	if err != nil || len(b) != 32 {
+		return nil, errors.New("TOKEN_KEY must be 32 bytes in base64")
+	}
+	return (*[32]byte)(b), nil
+}
+
+func seal(key *[32]byte, plain []byte) []byte {
+	var nonce [24]byte
+	rand.Read(nonce[:])
+	return secretbox.Seal(nonce[:], plain, &nonce, key)
+}
+
+func unseal(key *[32]byte, box []byte) ([]byte, bool) {
+	if len(box) < 24 {
+		return nil, false
+	}
+	return secretbox.Open(nil, box[24:], (*[24]byte)(box[:24]), key)
+}
+
+// openSecret returns v decrypted if it is sealed, and as is otherwise.
+func openSecret(v string) (string, error) {
+	rest, ok := strings.CutPrefix(v, sealedPrefix)
+	if !ok {
+		return v, nil
+	}
+	key, err := tokenKey()
+	if err != nil {
+		return "", err
+	}
+	if key == nil {
+		return "", errors.New("encrypted, but TOKEN_KEY is not set")
+	}
+	box, err := base64.StdEncoding.DecodeString(rest)
+	if err != nil {
+		return "", errors.New("encrypted value is not valid base64")
+	}
+	plain, ok := unseal(key, box)
+	if !ok {
+		return "", errors.New("could not decrypt with TOKEN_KEY")
+	}
+	return string(plain), nil
+}
+
+// secretEnv is os.Getenv for a value that may be sealed.
+func secretEnv(name string) (string, error) {
+	v, err := openSecret(strings.TrimSpace(os.Getenv(name)))
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", name, err)
+	}
+	return v, nil
+}
+
+// runEncryptToken reads a token from in and prints it sealed with
+// TOKEN_KEY. It returns the process exit code.
+func runEncryptToken(in io.Reader, out io.Writer) int {
+	key, err := tokenKey()
+	if err == nil && key == nil {
+		err = errors.New("set TOKEN_KEY first, e.g. to the output of `openssl rand -base64 32`")
+	}
+	if err != nil {
+		slog.Error("config", "err", err)
+		return 2
+	}
+	line, err := bufio.NewReader(in).ReadString('\n')
+	token := strings.TrimSpace(line)
+	if token == "" {
+		slog.Error("no token on stdin", "err", err)
+		return 2
+	}
+	fmt.Fprintln(out, sealedPrefix+base64.StdEncoding.EncodeToString(seal(key, []byte(token))))
+	return 0
+}
+
+// sessionKey seals session cookies; they carry the visitor's access and
+// refresh tokens.
+func (o *oauthConfig) sessionKey() *[32]byte {
+	k := sha256.Sum256(append([]byte("homepager session\x00"), o.SessionSecret...))
+	return &k
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"slices"
+)
+
+type sectionResponse struct {
+	Section string `json:"section"`
+	HTML    string `json:"html"`
+}
+
+// sectionHandler serves one dashboard section, rendered with the same
+// template as the full page, for the skeletons of ASYNC_SECTIONS.
+func sectionHandler(w http.ResponseWriter, r *http.Request) {
+	cfg, err := requestConfig(r)
+	if err != nil {
+		writeConfigError(w, r, err)
+		return
+	}
+	name := r.PathValue("name")
+	if !cfg.enabled(name) {
+		http.NotFound(w, r)
+		return
+	}
+	queryConfig(r, &cfg)
+	sections := []string{name}
+	switch {
+	case name == "todos" && envBool("DEDUP_TODOS"):
+		// Deduplicating todos needs the MRs they point at.
+		for _, sec := range []string{"mine", "team"} {
+			if cfg.enabled(sec) {
+				sections = append(sections, sec)
+			}
+		}
+	case name == "approved" && cfg.enabled("mine"):
+		// Approved MRs already shown under mine are left out.
+		sections = append(sections, "mine")
+	}
+	d, err := collectOrPolled(r.Context(), cfg, sections)
+	if err != nil {
+		slog.Warn("dashboard incomplete", "section", name, "err", errorHint(err))
+	}
+	d = prepareDashboard(r, cfg, d, err)
+	// Only this section's failures; the other sections show their own.
+	d.Failures = slices.DeleteFunc(d.Failures, func(f failure) bool {
+		return !failureInSection(name, f.Part)
+	})
+	tmpl, err := pageTemplate()
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	var buf bytes.Buffer
+	if err := tmpl.ExecuteTemplate(&buf, "failures", d); err != nil {
+		slog.Error("template", "section", name, "err", err)
+	}
+	if err := tmpl.ExecuteTemplate(&buf, name, d); err != nil {
+		slog.Error("template", "section", name, "err", err)
+		http.Error(w, "could not render section", 500)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	_ = json.NewEncoder(w).Encode(sectionResponse{Section: name, HTML: buf.String()})
+}
+
+// failureInSection says whether a failed part shows under section name;
+// "mine" also covers the assigned and reviewer lookups it is built from.
+func failureInSection(name, part string) bool {
+	if name == "mine" {
+		return part == "part.mine" || part == "part.assigned" || part == "part.reviewer"
+	}
+	return part == "part."+name
+}
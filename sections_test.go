@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+)
+
+func TestSectionQueries(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		queries []string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v4/version":
+			w.Write([]byte(`{"version":"17.0.0"}`))
+		case "/api/v4/merge_requests":
+			mu.Lock()
+			queries = append(queries, r.URL.RawQuery)
+			mu.Unlock()
+			w.Write([]byte(`[]`))
+		default:
+			w.Write([]byte(`[]`))
+		}
+	}))
+	defer srv.Close()
+	h := gitlabHost{Base: srv.URL, Name: "sections", User: "me"}
+	cfg := config{Hosts: []gitlabHost{h}, TeamUsers: []string{"bob"}, Labels: []string{"bug", "needs review"}, Milestone: "Sprint 1/2 & more"}
+	ctx := context.Background()
+
+	const filter = "&per_page=100&labels=bug%2Cneeds+review&milestone=Sprint+1%2F2+%26+more&include=head_pipeline"
+	for _, tt := range []struct {
+		section string
+		collect func() error
+		want    []string
+	}{
+		{"mine", func() error { _, err := collectMine(ctx, cfg, h); return err }, []string{
+			"scope=all&state=opened&assignee_username=me" + filter,
+			"scope=all&state=opened&reviewer_username=me" + filter,
+		}},
+		{"team", func() error { _, err := collectTeam(ctx, cfg, h); return err }, []string{
+			"scope=all&state=opened&assignee_username=bob" + filter,
+			"scope=all&state=opened&author_username=bob" + filter,
+		}},
+		{"approved", func() error { _, err := collectApproved(ctx, cfg, h); return err }, []string{
+			"scope=all&state=opened&approved_by_usernames[]=me" + filter,
+		}},
+	} {
+		t.Run(tt.section, func(t *testing.T) {
+			mu.Lock()
+			queries = nil
+			mu.Unlock()
+			if err := tt.collect(); err != nil {
+				t.Fatal(err)
+			}
+			mu.Lock()
+			got := slices.Sorted(slices.Values(queries))
+			mu.Unlock()
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("queries:\n got %q\nwant %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMRFilter(t *testing.T) {
+	for _, tt := range []struct {
+		cfg  config
+		want string
+	}{
+		{config{}, ""},
+		{config{Labels: []string{"a&b"}}, "&labels=a%26b"},
+		{config{Milestone: "none"}, "&milestone=None"},
+		{config{Milestone: "v1.0"}, "&milestone=v1.0"},
+		{config{Labels: []string{"x"}, Milestone: "Upcoming"}, "&labels=x&milestone=Upcoming"},
+	} {
+		if got := tt.cfg.mrFilter(); got != tt.want {
+			t.Errorf("mrFilter(%+v) = %q, want %q", tt.cfg, got, tt.want)
+		}
+	}
+}
+
+func TestFailureInSection(t *testing.T) {
+	for _, tt := range []struct {
+		name, part string
+		want       bool
+	}{
+		{"mine", "part.mine", true},
+		{"mine", "part.assigned", true},
+		{"mine", "part.reviewer", true},
+		{"mine", "part.team", false},
+		{"team", "part.team", true},
+		{"team", "part.mine", false},
+	} {
+		if got := failureInSection(tt.name, tt.part); got != tt.want {
+			t.Errorf("failureInSection(%q, %q) = %v, want %v", tt.name, tt.part, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// Requests in flight get this long to finish after SIGTERM or SIGINT.
+const shutdownTimeout = 20 * time.Second
+
+// shuttingDown is closed when the server starts draining, so /events and
+// /ws end their streams instead of holding the shutdown up.
+var shuttingDown = make(chan struct{})
+
+// serve runs srv with listen until SIGTERM or SIGINT, then stops taking
+// connections, lets in-flight requests and the current poll finish, and
+// returns.
+func serve(srv *http.Server, listen func() error) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	errc := make(chan error, 1)
+	go func() { errc <- listen() }()
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+	stop() // a second signal kills the process as usual
+	slog.Info("shutting down", "timeout", shutdownTimeout)
+	close(shuttingDown)
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	err := srv.Shutdown(ctx)
+	polled.stop()
+	if lerr := <-errc; !errors.Is(lerr, http.ErrServerClosed) {
+		return lerr
+	}
+	return err
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// snoozeStore persists snoozed MRs per user in a small JSON file:
+// user -> MR key -> snoozed until.
+type snoozeStore struct {
+	mu   sync.Mutex
+	path string
+}
+
+var snoozes = &snoozeStore{}
+
+func configureSnoozes() {
+	snoozes.path = os.Getenv("SNOOZE_FILE")
+	if snoozes.path == "" {
+		snoozes.path = "snoozes.json"
+	}
+}
+
+func (s *snoozeStore) load() (map[string]map[string]time.Time, error) {
+	all := map[string]map[string]time.Time{}
+	b, err := os.ReadFile(s.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return all, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return all, json.Unmarshal(b, &all)
+}
+
+func (s *snoozeStore) save(all map[string]map[string]time.Time) error {
+	b, err := json.MarshalIndent(all, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.path, b)
+}
+
+// writeFileAtomic writes through a temp file so a crash never leaves half
+// a file.
+func writeFileAtomic(path string, b []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+"-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Active returns the user's snoozes that haven't expired yet.
+func (s *snoozeStore) Active(user string, now time.Time) (map[string]time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	all, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	active := map[string]time.Time{}
+	for key, until := range all[user] {
+		if until.After(now) {
+			active[key] = until
+		}
+	}
+	return active, nil
+}
+
+// Snooze hides key until the given time and drops the user's expired
+// snoozes while at it.
+func (s *snoozeStore) Snooze(user, key string, until time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	all, err := s.load()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	mine := map[string]time.Time{key: until}
+	for k, u := range all[user] {
+		if k != key && u.After(now) {
+			mine[k] = u
+		}
+	}
+	all[user] = mine
+	return s.save(all)
+}
+
+func (s *snoozeStore) Clear(user string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	all, err := s.load()
+	if err != nil {
+		return err
+	}
+	delete(all, user)
+	return s.save(all)
+}
+
+func filterSnoozed(mrs []MR, snoozed map[string]time.Time) []MR {
+	if len(snoozed) == 0 {
+		return mrs
+	}
+	out := make([]MR, 0, len(mrs))
+	for _, m := range mrs {
+		if _, ok := snoozed[m.Key()]; !ok {
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
+// snoozeZone is the zone "until" is read in: DISPLAY_TZ, else UTC. The
+// server's own zone would depend on where it happens to run.
+func snoozeZone(cfg config) *time.Location {
+	if os.Getenv("DISPLAY_TZ") != "" && cfg.Location != nil {
+		return cfg.Location
+	}
+	return time.UTC
+}
+
+// snoozeUntil reads either a duration ("for", e.g. 24h) or a
+// datetime-local value ("until", in loc) from the form.
+func snoozeUntil(r *http.Request, now time.Time, loc *time.Location) (time.Time, error) {
+	if v := r.FormValue("for"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return time.Time{}, errors.New("invalid snooze duration")
+		}
+		return now.Add(d), nil
+	}
+	t, err := time.ParseInLocation("2006-01-02T15:04", r.FormValue("until"), loc)
+	if err != nil || !t.After(now) {
+		return time.Time{}, errors.New("invalid snooze time")
+	}
+	return t, nil
+}
+
+func snoozeHandler(w http.ResponseWriter, r *http.Request) {
+	cfg, err := requestConfig(r)
+	if err != nil {
+		writeConfigError(w, r, err)
+		return
+	}
+	key := r.FormValue("key")
+	if key == "" {
+		http.Error(w, "missing MR key", http.StatusBadRequest)
+		return
+	}
+	until, err := snoozeUntil(r, time.Now(), snoozeZone(cfg))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := snoozes.Snooze(cfg.User, key, until); err != nil {
+		slog.Error("snoozes", "err", err)
+		http.Error(w, "could not save snooze", 500)
+		return
+	}
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+func unsnoozeAllHandler(w http.ResponseWriter, r *http.Request) {
+	cfg, err := requestConfig(r)
+	if err != nil {
+		writeConfigError(w, r, err)
+		return
+	}
+	if err := snoozes.Clear(cfg.User); err != nil {
+		slog.Error("snoozes", "err", err)
+		http.Error(w, "could not save snoozes", 500)
+		return
+	}
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSnoozeUntil(t *testing.T) {
+	ams, err := time.LoadLocation("Europe/Amsterdam")
+	if err != nil {
+		t.Skip(err)
+	}
+	now := time.Date(2026, 6, 1, 8, 0, 0, 0, time.UTC)
+	for _, tt := range []struct {
+		form url.Values
+		loc  *time.Location
+		want time.Time
+		err  bool
+	}{
+		{url.Values{"for": {"4h"}}, time.UTC, now.Add(4 * time.Hour), false},
+		{url.Values{"for": {"-1h"}}, time.UTC, time.Time{}, true},
+		{url.Values{"for": {""}, "until": {"2026-06-01T12:00"}}, time.UTC, time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC), false},
+		{url.Values{"until": {"2026-06-01T12:00"}}, ams, time.Date(2026, 6, 1, 10, 0, 0, 0, time.UTC), false},
+		{url.Values{"until": {"2026-06-01T09:00"}}, ams, time.Time{}, true}, // 07:00 UTC, already past
+		{url.Values{"until": {"tomorrow"}}, time.UTC, time.Time{}, true},
+	} {
+		r := httptest.NewRequest("POST", "/mrs/snooze", strings.NewReader(tt.form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		got, err := snoozeUntil(r, now, tt.loc)
+		if (err != nil) != tt.err || !got.Equal(tt.want) {
+			t.Errorf("snoozeUntil(%v, %s) = %v, %v; want %v, error %v", tt.form, tt.loc, got, err, tt.want, tt.err)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"crypto/sha256"
+	"embed"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"sync"
+)
+
+//go:embed static
+var staticFS embed.FS
+
+// With DEV=1 assets are served straight from disk, like the template.
+func assetFS() fs.FS {
+	if envBool("DEV") {
+		return os.DirFS(".")
+	}
+	return staticFS
+}
+
+// assetURLs memoizes assetURL; the embedded files never change.
+var assetURLs sync.Map // name -> URL
+
+// assetURL appends a content hash so browsers can cache assets forever
+// and still pick up a new version after a deploy. With DEV=1 it hashes
+// on every call, as the files may be edited.
+func assetURL(name string) string {
+	dev := envBool("DEV")
+	if u, ok := assetURLs.Load(name); ok && !dev {
+		return u.(string)
+	}
+	b, err := fs.ReadFile(assetFS(), "static/"+name)
+	if err != nil {
+		return "/static/" + name
+	}
+	sum := sha256.Sum256(b)
+	u := fmt.Sprintf("/static/%s?v=%x", name, sum[:6])
+	if !dev {
+		assetURLs.Store(name, u)
+	}
+	return u
+}
+
+func staticHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Has("v") && !envBool("DEV") {
+			w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+		}
+		http.FileServerFS(assetFS()).ServeHTTP(w, r)
+	})
+}
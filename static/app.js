@@ -0,0 +1,153 @@
+(function(){
+  const toggle = document.getElementById('drawer-toggle');
+  if (!toggle) return;
+  const setOpen = open => {
+    document.body.classList.toggle('drawer-open', open);
+    toggle.setAttribute('aria-expanded', open);
+  };
+  toggle.addEventListener('click', ()=>setOpen(!document.body.classList.contains('drawer-open')));
+  document.getElementById('drawer-backdrop').addEventListener('click', ()=>setOpen(false));
+  document.addEventListener('keydown', e=>{ if (e.key === 'Escape') setOpen(false); });
+})();
+document.getElementById('theme-toggle').addEventListener('click', ()=>{
+  const root = document.documentElement;
+  const dark = root.dataset.theme ? root.dataset.theme === 'dark' : matchMedia('(prefers-color-scheme: dark)').matches;
+  root.dataset.theme = dark ? 'light' : 'dark';
+  try { localStorage.setItem('theme', root.dataset.theme); } catch (e) {}
+});
+function timeago(dt){
+  const rtf = new Intl.RelativeTimeFormat(document.documentElement.lang || navigator.language, {numeric:'auto'});
+  const diff = (new Date(dt) - new Date()) / 1000;
+  const abs = Math.abs(diff);
+  const units = [['year',31536000],['month',2592000],['week',604800],['day',86400],['hour',3600],['minute',60],['second',1]];
+  for (const [unit, sec] of units){
+    if (abs >= sec || unit === 'second'){ return rtf.format(Math.round(diff / sec), unit); }
+  }
+}
+function refreshTimes(){
+  document.querySelectorAll('time.timeago').forEach(t=>{
+    const dt = t.getAttribute('datetime');
+    if (dt) t.textContent = timeago(dt);
+  });
+}
+const timeRefresh = Number(document.body.dataset.timeRefresh), pageRefresh = Number(document.body.dataset.pageRefresh);
+refreshTimes();
+if (timeRefresh > 0) setInterval(refreshTimes, timeRefresh * 1000);
+// With the background poller on, /events pushes every new snapshot and the
+// sections are swapped in place; scroll position and the open drawer stay.
+// Without it the page reloads every pageRefresh seconds.
+if ('events' in document.body.dataset && window.EventSource) {
+  const events = new EventSource('/events' + location.search);
+  events.addEventListener('update', e=>{
+    const u = JSON.parse(e.data);
+    if (u.caught_up !== !!document.querySelector('.caught-up')) { location.reload(); return; }
+    for (const [name, html] of Object.entries(u.sections)) {
+      const el = document.querySelector(`[data-live="${name}"]`);
+      if (el) el.outerHTML = html;
+    }
+    // Banners from async sections are folded into the one at the top.
+    document.querySelectorAll('.banner.failures').forEach(b=>{ if (!b.closest('[data-live="failures"]')) b.remove(); });
+    const failures = document.querySelector('[data-live="failures"]');
+    if (failures) failures.innerHTML = u.failures;
+    const polled = document.querySelector('[data-live="polled"]');
+    if (polled) polled.setAttribute('datetime', u.polled_at);
+    const teamCount = document.querySelector('[data-live="team-count"]');
+    if (teamCount) teamCount.textContent = u.team_count;
+    refreshTimes();
+    document.dispatchEvent(new Event('live:patched'));
+  });
+  // /ws carries per-card changes between polls: pipelines the watcher saw
+  // finish, and MRs that were merged or closed.
+  if (window.WebSocket) {
+    const ws = new WebSocket((location.protocol === 'https:' ? 'wss://' : 'ws://') + location.host + '/ws');
+    ws.addEventListener('message', e=>{
+      for (const c of JSON.parse(e.data).changes || []) {
+        if (c.removed) {
+          document.querySelectorAll(`#${CSS.escape('mr-' + c.key)}, li[data-mr="${CSS.escape(c.key)}"]`).forEach(el=>el.remove());
+          continue;
+        }
+        document.querySelectorAll(`.pipe-slot[data-mr="${CSS.escape(c.key)}"]`).forEach(el=>{ el.innerHTML = c.html; });
+      }
+    });
+  }
+} else if (pageRefresh > 0) {
+  setTimeout(()=>location.reload(), pageRefresh * 1000);
+}
+document.querySelectorAll('[data-section]').forEach(el=>{
+  fetch('/api/section/' + el.dataset.section + location.search, {headers:{Accept:'application/json'}})
+    .then(r=>{ if (!r.ok) throw new Error(r.status); return r.json(); })
+    .then(s=>{ el.outerHTML = s.html; refreshTimes(); })
+    .catch(()=>{ el.removeAttribute('aria-busy'); el.classList.remove('skeleton'); el.innerHTML = ''; el.append(Object.assign(document.createElement('div'), {className:'empty', textContent:el.dataset.error})); });
+});
+// j/k move between cards, Enter opens the selected one. The selection is
+// kept in memory only, so the periodic page reload starts fresh; live
+// updates keep it.
+(function(){
+  let selected = null;
+  document.addEventListener('live:patched', ()=>{
+    const card = selected && document.getElementById(selected);
+    if (card) card.classList.add('selected');
+  });
+  const select = card=>{
+    document.querySelectorAll('.card.selected').forEach(c=>c.classList.remove('selected'));
+    if (!card) return;
+    selected = card.id;
+    card.classList.add('selected');
+    card.focus({preventScroll:true});
+    card.scrollIntoView({block:'nearest'});
+  };
+  document.addEventListener('keydown', e=>{
+    if (e.metaKey || e.ctrlKey || e.altKey || e.target.closest('input, select, textarea, button, a')) return;
+    const cards = [...document.querySelectorAll('.card[tabindex]')];
+    if (!cards.length) return;
+    const current = e.target.closest('.card') || document.getElementById(selected);
+    const i = cards.indexOf(current);
+    if (e.key === 'j') {
+      select(cards[Math.min(i + 1, cards.length - 1)]);
+    } else if (e.key === 'k') {
+      select(cards[Math.max(i - 1, 0)]);
+    } else if (e.key === 'Enter' && current) {
+      const a = current.querySelector('.title a');
+      if (a) window.open(a.href, '_blank', 'noopener');
+    } else {
+      return;
+    }
+    e.preventDefault();
+  });
+})();
+document.querySelectorAll('select[data-autosubmit]').forEach(s=>s.addEventListener('change', ()=>s.form.submit()));
+// Web Push: the button subscribes this browser; the server pushes new
+// review requests and todos even while the tab is in the background.
+(()=>{
+  const btn = document.getElementById('push-toggle');
+  if (!btn || !('serviceWorker' in navigator) || !('PushManager' in window)) return;
+  btn.hidden = false;
+  const key = s=>{
+    const b = atob(s.replace(/-/g, '+').replace(/_/g, '/'));
+    return Uint8Array.from(b, c=>c.charCodeAt(0));
+  };
+  const show = on=>{
+    btn.textContent = on ? btn.dataset.subscribed : btn.dataset.unsubscribed;
+    btn.setAttribute('aria-pressed', on);
+  };
+  const reg = navigator.serviceWorker.register('/sw.js');
+  reg.then(r=>r.pushManager.getSubscription()).then(s=>show(!!s));
+  btn.addEventListener('click', async ()=>{
+    const r = await reg;
+    const existing = await r.pushManager.getSubscription();
+    if (existing) {
+      await fetch('/push/subscribe', {method: 'DELETE', body: JSON.stringify(existing)});
+      await existing.unsubscribe();
+      show(false);
+      return;
+    }
+    if (await Notification.requestPermission() !== 'granted') {
+      btn.textContent = btn.dataset.denied;
+      return;
+    }
+    const pub = await (await fetch('/push/key')).text();
+    const sub = await r.pushManager.subscribe({userVisibleOnly: true, applicationServerKey: key(pub)});
+    const res = await fetch('/push/subscribe', {method: 'POST', headers: {'Content-Type': 'application/json'}, body: JSON.stringify(sub)});
+    show(res.ok);
+  });
+})();
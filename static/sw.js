@@ -0,0 +1,14 @@
+// Shows Web Push notifications from homepager, and opens the MR or todo
+// when one is clicked.
+self.addEventListener('push', e=>{
+  const msg = e.data ? e.data.json() : {title: 'homepager'};
+  e.waitUntil(self.registration.showNotification(msg.title, {
+    body: msg.body || '',
+    tag: msg.tag,
+    data: {url: msg.url || '/'},
+  }));
+});
+self.addEventListener('notificationclick', e=>{
+  e.notification.close();
+  e.waitUntil(clients.openWindow(e.notification.data.url));
+});
@@ -0,0 +1,4 @@
+// Apply the saved theme before first paint; without one we follow the OS.
+(function(){
+  try { const t = localStorage.getItem('theme'); if (t) document.documentElement.dataset.theme = t; } catch (e) {}
+})();
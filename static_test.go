@@ -0,0 +1,25 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAssetURL(t *testing.T) {
+	u := assetURL("app.js")
+	if !strings.HasPrefix(u, "/static/app.js?v=") {
+		t.Fatalf("assetURL(app.js) = %q", u)
+	}
+	if again := assetURL("app.js"); again != u {
+		t.Errorf("assetURL changed between calls: %q, %q", u, again)
+	}
+	if got := assetURL("missing.js"); got != "/static/missing.js" {
+		t.Errorf("assetURL(missing.js) = %q", got)
+	}
+}
+
+func BenchmarkAssetURL(b *testing.B) {
+	for b.Loop() {
+		assetURL("app.js")
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+)
+
+type stats struct {
+	OpenMRs          int `json:"open_mrs"`
+	FailingPipelines int `json:"failing_pipelines"`
+	Conflicts        int `json:"conflicts"`
+	PendingTodos     int `json:"pending_todos"`
+
+	Client clientStats `json:"client"` // GitLab API retries since start
+}
+
+func dashboardStats(d dashboard) stats {
+	s := stats{OpenMRs: len(d.MRs), PendingTodos: len(d.Todos), Client: currentClientStats()}
+	for _, m := range d.MRs {
+		if m.HeadPipeline != nil && m.HeadPipeline.Status == "failed" {
+			s.FailingPipelines++
+		}
+		if m.HasConflicts {
+			s.Conflicts++
+		}
+	}
+	return s
+}
+
+func statsHandler(w http.ResponseWriter, r *http.Request) {
+	cfg, err := requestConfig(r)
+	if err != nil {
+		writeConfigError(w, r, err)
+		return
+	}
+	d, err := collectOrPolled(r.Context(), cfg, []string{"mine", "todos"})
+	if err != nil {
+		slog.Warn("dashboard incomplete", "err", errorHint(err))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(dashboardStats(d))
+}
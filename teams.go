@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+type team struct {
+	Name  string
+	Users []string
+}
+
+// parseTeams reads TEAMS, e.g. "backend:ann,bob;frontend:carol". Order is
+// kept; the first team is the default.
+func parseTeams(s string) ([]team, error) {
+	var teams []team
+	seen := map[string]bool{}
+	for _, entry := range strings.Split(s, ";") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		name, users, ok := strings.Cut(entry, ":")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("expected name:user1,user2 but got %q", entry)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("team %q listed twice", name)
+		}
+		seen[name] = true
+		teams = append(teams, team{Name: name, Users: splitUsers(users)})
+	}
+	return teams, nil
+}
+
+func (c config) teamNames() []string {
+	names := make([]string, len(c.Teams))
+	for i, t := range c.Teams {
+		names[i] = t.Name
+	}
+	return names
+}
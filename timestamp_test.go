@@ -0,0 +1,52 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestTimestampUnmarshal(t *testing.T) {
+	for _, tt := range []struct {
+		name, in string
+		want     time.Time
+		err      bool
+	}{
+		{"RFC3339", `"2026-03-01T10:00:00Z"`, time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC), false},
+		{"offset", `"2026-03-01T11:00:00+01:00"`, time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC), false},
+		{"milliseconds", `"2026-03-01T10:00:00.123Z"`, time.Date(2026, 3, 1, 10, 0, 0, 123e6, time.UTC), false},
+		{"nanoseconds", `"2026-03-01T10:00:00.123456789Z"`, time.Date(2026, 3, 1, 10, 0, 0, 123456789, time.UTC), false},
+		{"no colon in zone", `"2026-03-01T11:00:00.5+0100"`, time.Date(2026, 3, 1, 10, 0, 0, 5e8, time.UTC), false},
+		{"no zone", `"2026-03-01T10:00:00.250"`, time.Date(2026, 3, 1, 10, 0, 0, 25e7, time.UTC), false},
+		{"space, no zone", `"2026-03-01 10:00:00"`, time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC), false},
+		{"space, UTC", `"2026-03-01 10:00:00 UTC"`, time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC), false},
+		{"date only", `"2026-03-01"`, time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC), false},
+		{"null", `null`, time.Time{}, false},
+		{"empty", `""`, time.Time{}, false},
+		{"garbage", `"yesterday"`, time.Time{}, true},
+		{"number", `1700000000`, time.Time{}, true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var ts Timestamp
+			err := json.Unmarshal([]byte(tt.in), &ts)
+			if (err != nil) != tt.err {
+				t.Fatalf("err = %v, want error %v", err, tt.err)
+			}
+			if !ts.Equal(tt.want) {
+				t.Errorf("got %v, want %v", ts.Time, tt.want)
+			}
+		})
+	}
+}
+
+func TestTimestampInStruct(t *testing.T) {
+	var mr struct {
+		UpdatedAt Timestamp `json:"updated_at"`
+	}
+	if err := json.Unmarshal([]byte(`{"updated_at":"2026-03-01T10:00:00.000+00:00"}`), &mr); err != nil {
+		t.Fatal(err)
+	}
+	if mr.UpdatedAt.IsZero() {
+		t.Error("updated_at not decoded")
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// configureTLS picks how srv listens: plain HTTP by default, HTTPS with
+// TLS_CERT and TLS_KEY, or HTTPS with certificates from Let's Encrypt for
+// AUTOCERT_DOMAINS. Autocert keeps its account and certificates in
+// AUTOCERT_CACHE and answers the HTTP-01 challenge on :80, which otherwise
+// redirects to HTTPS.
+func configureTLS(srv *http.Server) (listen func() error, err error) {
+	cert, key := os.Getenv("TLS_CERT"), os.Getenv("TLS_KEY")
+	domains := splitUsers(os.Getenv("AUTOCERT_DOMAINS"))
+	switch {
+	case len(domains) > 0 && cert != "":
+		return nil, errors.New("set either TLS_CERT/TLS_KEY or AUTOCERT_DOMAINS, not both")
+	case (cert == "") != (key == ""):
+		return nil, errors.New("TLS_CERT and TLS_KEY go together")
+	case cert != "":
+		slog.Info("serving HTTPS", "cert", cert)
+		return func() error { return srv.ListenAndServeTLS(cert, key) }, nil
+	case len(domains) > 0:
+		cache := os.Getenv("AUTOCERT_CACHE")
+		if cache == "" {
+			cache = "autocert-cache"
+		}
+		m := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(domains...),
+			Cache:      autocert.DirCache(cache),
+			Email:      os.Getenv("AUTOCERT_EMAIL"),
+		}
+		srv.TLSConfig = m.TLSConfig()
+		go func() {
+			challenge := &http.Server{Addr: ":80", Handler: m.HTTPHandler(nil), ReadHeaderTimeout: 10 * time.Second}
+			// TLS-ALPN-01 on the HTTPS port still works without it.
+			slog.Warn("autocert: no HTTP listener", "err", challenge.ListenAndServe())
+		}()
+		slog.Info("serving HTTPS with Let's Encrypt", "domains", strings.Join(domains, ","), "cache", cache)
+		return func() error { return srv.ListenAndServeTLS("", "") }, nil
+	}
+	return srv.ListenAndServe, nil
+}
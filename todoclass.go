@@ -0,0 +1,43 @@
+package main
+
+import "time"
+
+// classifyTodo sorts a todo into "action" (I need to do something) or
+// "info" (FYI). Unknown action names count as action so nothing new gets
+// buried.
+func classifyTodo(t Todo) string {
+	switch t.ActionName {
+	case "mentioned", "marked":
+		return "info"
+	case "assigned", "review_requested", "directly_addressed", "build_failed":
+		return "action"
+	}
+	return "action"
+}
+
+type todoClass struct {
+	Title  string // message key, see catalogs
+	Todos  int
+	Groups []DateGroup
+}
+
+// classifyTodos splits todos into action required and informational, each
+// bucketed by day. Action required comes first; empty classes are dropped.
+func classifyTodos(todos []Todo, loc *time.Location) []todoClass {
+	var action, info []Todo
+	for _, t := range todos {
+		if classifyTodo(t) == "info" {
+			info = append(info, t)
+		} else {
+			action = append(action, t)
+		}
+	}
+	var out []todoClass
+	if len(action) > 0 {
+		out = append(out, todoClass{"todos.action", len(action), bucketByDate(action, loc)})
+	}
+	if len(info) > 0 {
+		out = append(out, todoClass{"todos.info", len(info), bucketByDate(info, loc)})
+	}
+	return out
+}
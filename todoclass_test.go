@@ -0,0 +1,44 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestClassifyTodo(t *testing.T) {
+	want := map[string]string{
+		"assigned":           "action",
+		"review_requested":   "action",
+		"directly_addressed": "action",
+		"build_failed":       "action",
+		"mentioned":          "info",
+		"marked":             "info",
+		"approval_required":  "action", // not listed: the default bucket
+		"unmergeable":        "action",
+		"":                   "action",
+	}
+	for action, class := range want {
+		for _, target := range []string{"MergeRequest", "Issue", "Commit", "Epic", "DesignManagement::Design"} {
+			todo := Todo{ActionName: action, TargetType: target}
+			if got := classifyTodo(todo); got != class {
+				t.Errorf("classifyTodo(%s on %s) = %q, want %q", action, target, got, class)
+			}
+		}
+	}
+}
+
+func TestClassifyTodos(t *testing.T) {
+	now := Timestamp{time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)}
+	todos := []Todo{
+		{ID: 1, ActionName: "mentioned", CreatedAt: now},
+		{ID: 2, ActionName: "assigned", CreatedAt: now},
+		{ID: 3, ActionName: "marked", CreatedAt: now},
+	}
+	classes := classifyTodos(todos, time.UTC)
+	if len(classes) != 2 || classes[0].Title != "todos.action" || classes[0].Todos != 1 || classes[1].Title != "todos.info" || classes[1].Todos != 2 {
+		t.Errorf("got %+v; want action required (1) before informational (2)", classes)
+	}
+	if classes := classifyTodos(todos[:1], time.UTC); len(classes) != 1 || classes[0].Title != "todos.info" {
+		t.Errorf("empty classes should be dropped, got %+v", classes)
+	}
+}
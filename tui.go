@@ -0,0 +1,256 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+
+	"golang.org/x/term"
+)
+
+// tuiRow is one line of the terminal dashboard; headings have no URL and
+// can't be selected.
+type tuiRow struct {
+	text string
+	url  string
+}
+
+// tuiRows lays out the sections in SECTIONS order.
+func tuiRows(cfg config, d dashboard) []tuiRow {
+	var rows []tuiRow
+	mrs := func(title string, list []MR) {
+		rows = append(rows, tuiRow{text: fmt.Sprintf("%s (%d)", title, len(list))})
+		for _, m := range list {
+			status := "-"
+			if m.HeadPipeline != nil {
+				status = m.HeadPipeline.Status
+			}
+			rows = append(rows, tuiRow{text: fmt.Sprintf("  %-9s %s  %s", status, m.Title, m.References.Full), url: m.WebURL})
+		}
+	}
+	for _, sec := range cfg.Sections {
+		switch sec {
+		case "mine":
+			mrs("Merge requests", d.MRs)
+		case "team":
+			mrs("Team MRs", d.TeamMRs)
+		case "approved":
+			mrs("Approved by me", d.Approved)
+		case "issues":
+			rows = append(rows, tuiRow{text: fmt.Sprintf("Issues (%d)", len(d.Issues))})
+			for _, i := range d.Issues {
+				rows = append(rows, tuiRow{text: fmt.Sprintf("  %s  %s", i.Title, i.References.Full), url: i.WebURL})
+			}
+		case "todos":
+			rows = append(rows, tuiRow{text: fmt.Sprintf("Todos (%d)", len(d.Todos))})
+			for _, t := range d.Todos {
+				rows = append(rows, tuiRow{text: fmt.Sprintf("  %-9s %s  %s", t.ActionName, t.Target.Title, t.Project.Name), url: t.Target.WebURL})
+			}
+		}
+		rows = append(rows, tuiRow{})
+	}
+	return rows
+}
+
+type tuiResult struct {
+	d   dashboard
+	err error
+}
+
+// runTUI keeps the dashboard in the terminal until q: j/k or the arrows
+// move, Enter opens the selected item in the browser, r reloads. It
+// reloads by itself every REFRESH_SECONDS. It returns the process exit
+// code.
+func runTUI() int {
+	cfg, err := loadConfig()
+	if err != nil {
+		slog.Error("config", "err", err)
+		return 2
+	}
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		slog.Error("-tui needs a terminal")
+		return 2
+	}
+	old, err := term.MakeRaw(fd)
+	if err != nil {
+		slog.Error("terminal", "err", err)
+		return 1
+	}
+	defer term.Restore(fd, old)
+	// Log lines would scribble over the screen; failures show in the
+	// footer instead.
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	out := os.Stdout
+	fmt.Fprint(out, "\x1b[?1049h\x1b[?25l") // alternate screen, no cursor
+	defer fmt.Fprint(out, "\x1b[?25h\x1b[?1049l")
+
+	keys := make(chan string)
+	go func() {
+		buf := make([]byte, 16)
+		for {
+			n, err := os.Stdin.Read(buf)
+			if err != nil {
+				close(keys)
+				return
+			}
+			keys <- string(buf[:n])
+		}
+	}()
+	results := make(chan tuiResult, 1)
+	load := func() {
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+			d, err := collectDashboard(ctx, cfg)
+			results <- tuiResult{d, err}
+		}()
+	}
+	load()
+	every := time.Duration(max(cfg.RefreshSeconds, 10)) * time.Second
+	tick := time.NewTicker(every)
+	defer tick.Stop()
+
+	var (
+		rows     []tuiRow
+		sel, top int
+		loading  = true
+		status   string
+		loadedAt time.Time
+	)
+	move := func(step int) {
+		for i := sel + step; i >= 0 && i < len(rows); i += step {
+			if rows[i].url != "" {
+				sel = i
+				return
+			}
+		}
+	}
+	for {
+		w, h, err := term.GetSize(fd)
+		if err != nil || w <= 0 || h <= 0 {
+			w, h = 80, 24
+		}
+		top = tuiDraw(out, cfg, rows, sel, top, w, h, loading, loadedAt, status)
+		select {
+		case r := <-results:
+			loading, loadedAt, status = false, time.Now(), ""
+			if r.err != nil {
+				status = fmt.Sprintf("%d list(s) failed to load", len(failures(r.err)))
+			}
+			rows = tuiRows(cfg, r.d)
+			if sel >= len(rows) || rows[sel].url == "" {
+				sel = -1
+				move(1)
+				sel = max(sel, 0)
+			}
+		case <-tick.C:
+			if !loading {
+				loading = true
+				load()
+			}
+		case k, ok := <-keys:
+			if !ok {
+				return 0
+			}
+			switch k {
+			case "q", "\x03", "\x1b":
+				return 0
+			case "j", "\x1b[B":
+				move(1)
+			case "k", "\x1b[A":
+				move(-1)
+			case "g":
+				sel = -1
+				move(1)
+				sel = max(sel, 0)
+			case "G":
+				sel = len(rows)
+				move(-1)
+				sel = min(sel, max(len(rows)-1, 0))
+			case "r":
+				if !loading {
+					loading = true
+					load()
+				}
+			case "\r", "\n":
+				if sel < len(rows) && rows[sel].url != "" {
+					if err := openBrowser(rows[sel].url); err != nil {
+						status = "could not open the browser: " + err.Error()
+					}
+				}
+			}
+		}
+	}
+}
+
+// tuiDraw paints the whole screen and returns the scroll offset that keeps
+// sel in view.
+func tuiDraw(out io.Writer, cfg config, rows []tuiRow, sel, top, w, h int, loading bool, at time.Time, status string) int {
+	var b strings.Builder
+	b.WriteString("\x1b[H\x1b[2J")
+	header := "homepager — " + cfg.User
+	if !at.IsZero() {
+		header += "  " + at.Format("15:04:05")
+	}
+	if loading {
+		header += "  loading…"
+	}
+	fmt.Fprintf(&b, "\x1b[1m%s\x1b[0m\r\n\r\n", clip(header, w))
+	view := max(h-4, 1)
+	if sel < top {
+		top = sel
+	}
+	if sel >= top+view {
+		top = sel - view + 1
+	}
+	for i := top; i < len(rows) && i < top+view; i++ {
+		line := clip(rows[i].text, w)
+		switch {
+		case i == sel && rows[i].url != "":
+			fmt.Fprintf(&b, "\x1b[7m%s\x1b[0m\r\n", line)
+		case rows[i].url == "":
+			fmt.Fprintf(&b, "\x1b[1m%s\x1b[0m\r\n", line)
+		default:
+			b.WriteString(line + "\r\n")
+		}
+	}
+	footer := "j/k move  enter open  r reload  q quit"
+	if status != "" {
+		footer = status + "  —  " + footer
+	}
+	fmt.Fprintf(&b, "\x1b[%d;1H\x1b[2m%s\x1b[0m", h, clip(footer, w))
+	io.WriteString(out, b.String())
+	return top
+}
+
+// clip cuts s to w columns, counting runes.
+func clip(s string, w int) string {
+	r := []rune(s)
+	if len(r) <= w {
+		return s
+	}
+	if w < 1 {
+		return ""
+	}
+	return string(r[:w-1]) + "…"
+}
+
+func openBrowser(url string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", url)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", url)
+	default:
+		cmd = exec.Command("xdg-open", url)
+	}
+	return cmd.Start()
+}
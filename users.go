@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// With the OAuth login one deployment serves a whole team. Each visitor
+// has their own session cookie already; this adds their own teammate list
+// and a short-lived cache of their dashboard, since the poller only
+// collects for the configured defaults.
+
+// teammateStore persists each user's teammate list in a small JSON file:
+// user -> usernames.
+type teammateStore struct {
+	mu   sync.Mutex
+	path string
+}
+
+var teammates = &teammateStore{}
+
+func configureTeammates() {
+	teammates.path = os.Getenv("TEAMMATES_FILE")
+	if teammates.path == "" {
+		teammates.path = "teammates.json"
+	}
+}
+
+func (s *teammateStore) load() (map[string][]string, error) {
+	all := map[string][]string{}
+	b, err := os.ReadFile(s.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return all, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return all, json.Unmarshal(b, &all)
+}
+
+// Get returns the user's own teammates; ok is false when they never set
+// any and the configured team applies.
+func (s *teammateStore) Get(user string) (users []string, ok bool, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	all, err := s.load()
+	if err != nil {
+		return nil, false, err
+	}
+	users, ok = all[user]
+	return users, ok, nil
+}
+
+// Set stores the user's teammates; an empty list goes back to the
+// configured team.
+func (s *teammateStore) Set(user string, users []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	all, err := s.load()
+	if err != nil {
+		return err
+	}
+	if len(users) == 0 {
+		delete(all, user)
+	} else {
+		all[user] = users
+	}
+	b, err := json.MarshalIndent(all, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.path, b)
+}
+
+// personalTeam puts the visitor's own teammate list in cfg, if they have
+// one. ?team= still picks a configured team over it.
+func personalTeam(cfg *config) {
+	users, ok, err := teammates.Get(cfg.User)
+	if err != nil {
+		slog.Error("teammates", "err", err)
+		return
+	}
+	if ok {
+		cfg.Team, cfg.TeamUsers, cfg.PersonalTeam = "", users, true
+	}
+}
+
+func teammatesHandler(w http.ResponseWriter, r *http.Request) {
+	cfg, err := requestConfig(r)
+	if err != nil {
+		writeConfigError(w, r, err)
+		return
+	}
+	if cfg.OAuth == nil {
+		// Without the login everyone is the same user; TEAMMATE_USERNAMES
+		// is the list.
+		http.NotFound(w, r)
+		return
+	}
+	if err := teammates.Set(cfg.User, splitUsers(r.FormValue("users"))); err != nil {
+		slog.Error("teammates", "err", err)
+		http.Error(w, "could not save teammates", 500)
+		return
+	}
+	userCache.forget(cfg.User)
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// userSnapshots caches collected dashboards per user for
+// USER_CACHE_SECONDS, so reloads and the section requests of one visitor
+// don't each cost a round of GitLab calls.
+type userSnapshots struct {
+	mu      sync.Mutex
+	ttl     time.Duration // 0 is off
+	entries map[string]userSnapshot
+}
+
+type userSnapshot struct {
+	user string
+	d    dashboard
+	err  error
+	at   time.Time
+}
+
+var userCache = &userSnapshots{entries: map[string]userSnapshot{}}
+
+func configureUserCache() error {
+	secs, err := envInt("USER_CACHE_SECONDS", 60)
+	if err != nil {
+		return err
+	}
+	userCache.ttl = time.Duration(max(secs, 0)) * time.Second
+	return nil
+}
+
+// userCacheKey is everything a visitor can change about what is fetched.
+func userCacheKey(cfg config, sections []string) string {
+	return strings.Join([]string{
+		cfg.User, cfg.Team, cfg.Milestone,
+		strings.Join(cfg.TeamUsers, ","),
+		strings.Join(cfg.Labels, ","),
+		strings.Join(sections, ","),
+	}, "\x00")
+}
+
+func (c *userSnapshots) collect(ctx context.Context, cfg config, sections []string) (dashboard, error) {
+	if c.ttl == 0 {
+		return collectSections(ctx, cfg, sections)
+	}
+	key := userCacheKey(cfg, sections)
+	now := time.Now()
+	c.mu.Lock()
+	s, ok := c.entries[key]
+	c.mu.Unlock()
+	if ok && now.Sub(s.at) < c.ttl {
+		d := s.d
+		d.MRs, d.TeamMRs, d.Approved = slices.Clone(d.MRs), slices.Clone(d.TeamMRs), slices.Clone(d.Approved)
+		d.Issues, d.Todos = slices.Clone(d.Issues), slices.Clone(d.Todos)
+		return d, s.err
+	}
+	d, err := collectSections(ctx, cfg, sections)
+	if ctx.Err() != nil {
+		return d, err // cut short; don't keep it
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for k, e := range c.entries {
+		if now.Sub(e.at) >= c.ttl {
+			delete(c.entries, k)
+		}
+	}
+	c.entries[key] = userSnapshot{user: cfg.User, d: d, err: err, at: now}
+	return d, err
+}
+
+// forget drops the user's snapshots, after they changed something.
+func (c *userSnapshots) forget(user string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for k, e := range c.entries {
+		if e.user == user {
+			delete(c.entries, k)
+		}
+	}
+}
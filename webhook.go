@@ -0,0 +1,81 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"os"
+)
+
+// webhookEvent holds the fields homepager needs from GitLab's merge
+// request, pipeline and note hooks.
+type webhookEvent struct {
+	ObjectKind string `json:"object_kind"`
+	Project    struct {
+		ID int `json:"id"`
+	} `json:"project"`
+	ObjectAttributes struct {
+		ID     int    `json:"id"`     // pipeline hooks
+		IID    int    `json:"iid"`    // merge request hooks
+		State  string `json:"state"`  // merge request hooks
+		Status string `json:"status"` // pipeline hooks
+		URL    string `json:"url"`
+	} `json:"object_attributes"`
+	MergeRequest *struct {
+		IID int `json:"iid"`
+	} `json:"merge_request"` // pipeline and note hooks, nil when not on an MR
+}
+
+// mrIID is the MR the event is about, or 0 when there is none.
+func (e webhookEvent) mrIID() int {
+	switch e.ObjectKind {
+	case "merge_request":
+		return e.ObjectAttributes.IID
+	case "pipeline", "note":
+		if e.MergeRequest != nil {
+			return e.MergeRequest.IID
+		}
+	}
+	return 0
+}
+
+// webhookHandler receives GitLab hooks on /hooks/gitlab (and the older
+// /webhook). It drops the cached pipeline of the MR they concern, patches
+// the poller's snapshot where the hook says enough (a pipeline's new
+// status, an MR merged or closed) and asks for a poll for the rest. It is
+// only enabled with GITLAB_WEBHOOK_SECRET and sits outside dashboardAuth:
+// GitLab authenticates with the X-Gitlab-Token header instead.
+func webhookHandler(w http.ResponseWriter, r *http.Request) {
+	secret := os.Getenv("GITLAB_WEBHOOK_SECRET")
+	if secret == "" {
+		http.NotFound(w, r)
+		return
+	}
+	got, want := sha256.Sum256([]byte(r.Header.Get("X-Gitlab-Token"))), sha256.Sum256([]byte(secret))
+	if subtle.ConstantTimeCompare(got[:], want[:]) != 1 {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	var e webhookEvent
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&e); err != nil {
+		http.Error(w, "invalid payload", http.StatusBadRequest)
+		return
+	}
+	if iid := e.mrIID(); iid != 0 && e.Project.ID != 0 {
+		if n := pipelines.invalidate(e.Project.ID, iid); n > 0 {
+			slog.Info("webhook dropped cached pipeline", "event", e.ObjectKind, "project", e.Project.ID, "mr", iid)
+		}
+		switch a := e.ObjectAttributes; e.ObjectKind {
+		case "pipeline":
+			polled.applyPipeline(e.Project.ID, iid, Pipeline{ID: a.ID, Status: a.Status, WebURL: a.URL})
+		case "merge_request":
+			if a.State == "merged" || a.State == "closed" {
+				polled.dropMR(e.Project.ID, iid)
+			}
+		}
+		polled.refresh()
+	}
+	w.WriteHeader(http.StatusOK)
+}
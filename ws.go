@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/coder/websocket/wsjson"
+)
+
+// wsChange is one card update on /ws. HTML replaces the card's pipeline
+// slot; a removed MR is merged, closed or no longer waiting on me.
+type wsChange struct {
+	Key      string `json:"key"`
+	Pipeline string `json:"pipeline,omitempty"` // status; empty without one
+	HTML     string `json:"html,omitempty"`
+	Removed  bool   `json:"removed,omitempty"`
+}
+
+type wsMessage struct {
+	Changes []wsChange `json:"changes"`
+}
+
+// wsHandler streams pipeline and MR status changes as the poller and the
+// pipeline watcher (PIPELINE_WATCH_SECONDS) see them, so a dot turns red
+// seconds after the pipeline fails. Whole sections still come over
+// /events; this only carries the per-card changes in between.
+func wsHandler(w http.ResponseWriter, r *http.Request) {
+	if !polled.running() {
+		http.NotFound(w, r)
+		return
+	}
+	if _, err := requestConfig(r); err != nil {
+		writeConfigError(w, r, err)
+		return
+	}
+	tmpl, err := pageTemplate()
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	// Accept refuses other origins unless told otherwise.
+	c, err := websocket.Accept(w, r, nil)
+	if err != nil {
+		slog.Warn("ws: accept failed", "err", err)
+		return
+	}
+	defer c.CloseNow()
+	changes, stop := polled.watchChanges()
+	defer stop()
+	// Nothing is read from the client; this handles pings and the close.
+	ctx := c.CloseRead(r.Context())
+	keepalive := time.NewTicker(30 * time.Second)
+	defer keepalive.Stop()
+	for {
+		var msg wsMessage
+		select {
+		case <-ctx.Done():
+			c.Close(websocket.StatusNormalClosure, "")
+			return
+		case <-shuttingDown:
+			c.Close(websocket.StatusGoingAway, "server shutting down")
+			return
+		case <-keepalive.C:
+			if err := pingCtx(ctx, c); err != nil {
+				return
+			}
+			continue
+		case batch := <-changes:
+			for _, ch := range batch {
+				wc := wsChange{Key: ch.MR.Key(), Removed: ch.Removed}
+				if !ch.Removed {
+					if p := ch.MR.HeadPipeline; p != nil {
+						wc.Pipeline = p.Status
+					}
+					var buf bytes.Buffer
+					if err := tmpl.ExecuteTemplate(&buf, "pipe-status", ch.MR); err != nil {
+						slog.Error("template", "err", err)
+						continue
+					}
+					wc.HTML = buf.String()
+				}
+				msg.Changes = append(msg.Changes, wc)
+			}
+		}
+		wctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		err := wsjson.Write(wctx, c, msg)
+		cancel()
+		if err != nil {
+			return
+		}
+	}
+}
+
+func pingCtx(ctx context.Context, c *websocket.Conn) error {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	return c.Ping(ctx)
+}